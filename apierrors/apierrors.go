@@ -0,0 +1,179 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// Stable codes of the errors, clients can rely on them unlike on the detail messages
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthenticated      = "unauthenticated"
+	CodePermissionDenied     = "permission_denied"
+	CodeNotFound             = "not_found"
+	CodeAlreadyExists        = "already_exists"
+	CodeStillReferenced      = "still_referenced"
+	CodeConflict             = "conflict"
+	CodeInsufficientStock    = "insufficient_stock"
+	CodeVersionMismatch      = "version_mismatch"
+	CodePreconditionRequired = "precondition_required"
+	CodeUpstreamFailed       = "upstream_failed"
+	CodeInternal             = "internal_error"
+)
+
+// Codes of FieldError
+const (
+	FieldRequired      = "required"
+	FieldInvalid       = "invalid"
+	FieldNotFound      = "not_found"
+	FieldAlreadyExists = "already_exists"
+	FieldTooLong       = "too_long"
+	FieldOutOfRange    = "out_of_range"
+)
+
+// FieldError is the validation error of a field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+/*
+Error is an error of the api replied as a RFC 7807 problem.
+  - Code is one of the Code constants
+  - Extensions are added to the problem, e.g. the current version of a product
+  - Err is the cause, it is logged but never replied
+*/
+type Error struct {
+	Status     int
+	Code       string
+	Detail     string
+	Fields     []FieldError
+	Extensions map[string]interface{}
+	Err        error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%v: %v", e.Detail, e.Err)
+	}
+	return e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With adds an extension member to the problem
+func (e *Error) With(name string, value interface{}) *Error {
+	if e.Extensions == nil {
+		e.Extensions = make(map[string]interface{})
+	}
+	e.Extensions[name] = value
+	return e
+}
+
+// Problem documents the body of an error response, served as application/problem+json with the extensions of the Error
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+func New(status int, code string, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func BadRequest(detail string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, detail)
+}
+
+// InvalidParam is a 400 with the error of a query or path parameter
+func InvalidParam(param string, message string) *Error {
+	apiErr := BadRequest(message)
+	apiErr.Fields = []FieldError{{Field: param, Code: FieldInvalid, Message: message}}
+	return apiErr
+}
+
+// Validation is a 422 with the errors of the fields
+func Validation(fields ...FieldError) *Error {
+	return &Error{Status: http.StatusUnprocessableEntity, Code: CodeValidationFailed, Detail: "invalid fields", Fields: fields}
+}
+
+// InvalidField is a 422 with the error of one field
+func InvalidField(field string, message string) *Error {
+	return Validation(FieldError{Field: field, Code: FieldInvalid, Message: message})
+}
+
+func Unauthenticated(detail string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthenticated, detail)
+}
+
+func PermissionDenied(detail string) *Error {
+	return New(http.StatusForbidden, CodePermissionDenied, detail)
+}
+
+func NotFound(detail string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, detail)
+}
+
+func Conflict(detail string) *Error {
+	return New(http.StatusConflict, CodeConflict, detail)
+}
+
+// Internal is a 500, err is logged and detail is replied
+func Internal(err error, detail string) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Detail: detail, Err: err}
+}
+
+// Reply writes err as application/problem+json and aborts the request, an error which is not an *Error is a 500
+func Reply(c *gin.Context, err error) {
+	status, body := ProblemOf(c, err)
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, body)
+}
+
+// ProblemOf returns the status and the problem replied for err, e.g. to embed the errors of a bulk request
+func ProblemOf(c *gin.Context, err error) (int, gin.H) {
+	// an error returned through a transaction or wrapped with %w keeps its status
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal(err, "internal error")
+	}
+	if apiErr.Err != nil {
+		fmt.Println(apiErr.Err)
+	}
+
+	body := gin.H{
+		"type":     "about:blank",
+		"title":    http.StatusText(apiErr.Status),
+		"status":   apiErr.Status,
+		"code":     apiErr.Code,
+		"instance": c.Request.URL.Path,
+	}
+	for name, value := range apiErr.Extensions {
+		body[name] = value
+	}
+
+	if apiErr.Detail != "" {
+		body["detail"] = apiErr.Detail
+	}
+	// set by middlewares.RequestID
+	if requestID := c.GetString("request_id"); requestID != "" {
+		body["request_id"] = requestID
+	}
+	if len(apiErr.Fields) > 0 {
+		body["errors"] = apiErr.Fields
+	}
+
+	return apiErr.Status, body
+}
@@ -0,0 +1,76 @@
+package apierrors
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"manage-products/utils"
+	"reflect"
+	"strings"
+)
+
+/*
+init configures the validator of gin:
+  - the fields of the validation errors are named by their json or form tag instead of the go field
+  - decimals are validated as numbers, e.g. required or min=0
+*/
+func init() {
+	if validate, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+			for _, tag := range []string{"json", "form"} {
+				name := strings.Split(field.Tag.Get(tag), ",")[0]
+				if name == "-" {
+					return ""
+				}
+				if name != "" {
+					return name
+				}
+			}
+			return field.Name
+		})
+		validate.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+			return field.Interface().(utils.Decimal).Float64()
+		}, utils.Decimal{})
+	}
+}
+
+// FromBinding maps an error of c.ShouldBind to a 422 with the invalid fields, or a 400 when the request can not be parsed
+func FromBinding(err error) *Error {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		apiErr := Validation()
+		for _, fieldErr := range validationErrors {
+			code := FieldInvalid
+			if fieldErr.Tag() == "required" {
+				code = FieldRequired
+			}
+			apiErr.Fields = append(apiErr.Fields, FieldError{
+				Field:   fieldErr.Field(),
+				Code:    code,
+				Message: validationMessage(fieldErr),
+			})
+		}
+		return apiErr
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return InvalidField(typeErr.Field, typeErr.Field+" must be a "+typeErr.Type.String())
+	}
+
+	return BadRequest("invalid request: " + err.Error())
+}
+
+func validationMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return fieldErr.Field() + " is required"
+	case "email":
+		return fieldErr.Field() + " must be an email"
+	}
+	if fieldErr.Param() != "" {
+		return fieldErr.Field() + " must be " + fieldErr.Tag() + " " + fieldErr.Param()
+	}
+	return fieldErr.Field() + " is invalid"
+}
@@ -0,0 +1,96 @@
+package apierrors
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// keyColumns reads the columns of a constraint violation from its detail, e.g. Key (category_id)=(12) is not present in table "categories".
+var keyColumns = regexp.MustCompile(`^Key \(([^)]+)\)=`)
+
+/*
+FromDB maps an error of the database to an api error:
+  - pg.ErrNoRows: 404
+  - 23505 unique_violation: 409 already_exists
+  - 23503 foreign_key_violation: 422 when the referenced row does not exist, 409 still_referenced when a row is referenced
+  - 23502 not_null_violation, 23514 check_violation, 22001 string too long, 22003 out of range: 422
+  - 22P02 invalid_text_representation, e.g. an id which is not a number: 400
+  - 40001 serialization_failure, 40P01 deadlock_detected: 409, the client can retry
+  - an api error returned by a transaction is kept
+  - anything else is a 500 with detail
+*/
+func FromDB(err error, detail string) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, pg.ErrNoRows) {
+		return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Detail: "not found", Err: err}
+	}
+
+	var pgErr pg.Error
+	if !errors.As(err, &pgErr) {
+		return Internal(err, detail)
+	}
+
+	fields := constraintFields(pgErr)
+	switch pgErr.Field('C') {
+	case "23505":
+		apiErr := &Error{Status: http.StatusConflict, Code: CodeAlreadyExists, Detail: "already exists", Err: err}
+		for _, field := range fields {
+			apiErr.Fields = append(apiErr.Fields, FieldError{Field: field, Code: FieldAlreadyExists, Message: field + " already exists"})
+		}
+		return apiErr
+	case "23503":
+		if strings.Contains(pgErr.Field('D'), "is still referenced") {
+			return &Error{Status: http.StatusConflict, Code: CodeStillReferenced, Detail: "still referenced by " + pgErr.Field('t'), Err: err}
+		}
+		return validationError(fields, FieldNotFound, "%v not exists", err)
+	case "23502":
+		return validationError([]string{pgErr.Field('c')}, FieldRequired, "%v is required", err)
+	case "23514":
+		return validationError(fields, FieldInvalid, "%v is invalid", err)
+	case "22001":
+		return validationError(fields, FieldTooLong, "%v is too long", err)
+	case "22003":
+		return validationError(fields, FieldOutOfRange, "%v is out of range", err)
+	case "22P02":
+		return &Error{Status: http.StatusBadRequest, Code: CodeInvalidRequest, Detail: "invalid value", Err: err}
+	case "40001", "40P01":
+		return &Error{Status: http.StatusConflict, Code: CodeConflict, Detail: "concurrent update, retry the request", Err: err}
+	}
+
+	return Internal(err, detail)
+}
+
+// constraintFields returns the columns of the violated constraint, the column of the error if the detail has no key
+func constraintFields(pgErr pg.Error) []string {
+	if match := keyColumns.FindStringSubmatch(pgErr.Field('D')); match != nil {
+		fields := strings.Split(match[1], ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		return fields
+	}
+
+	if column := pgErr.Field('c'); column != "" {
+		return []string{column}
+	}
+	if constraint := pgErr.Field('n'); constraint != "" {
+		return []string{constraint}
+	}
+	return nil
+}
+
+func validationError(fields []string, code string, message string, err error) *Error {
+	apiErr := Validation()
+	apiErr.Err = err
+	for _, field := range fields {
+		apiErr.Fields = append(apiErr.Fields, FieldError{Field: field, Code: code, Message: fmt.Sprintf(message, field)})
+	}
+	return apiErr
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// check returns the error message of a value of the attribute, empty if it is valid
+func (attribute CategoryAttribute) check(value interface{}) string {
+	switch attribute.Type {
+	case "number":
+		switch value.(type) {
+		case float64, json.Number:
+			return ""
+		}
+		return "must be a number"
+	case "date":
+		if text, ok := value.(string); ok {
+			if _, err := time.Parse(time.DateOnly, text); err == nil {
+				return ""
+			}
+		}
+		return "must be a date YYYY-MM-DD"
+	case "enum":
+		if text, ok := value.(string); ok {
+			for _, accepted := range attribute.Values {
+				if text == accepted {
+					return ""
+				}
+			}
+		}
+		return fmt.Sprintf("must be one of %v", attribute.Values)
+	}
+
+	if _, ok := value.(string); !ok {
+		return "must be a string"
+	}
+	return ""
+}
+
+/*
+validateProductAttributes checks the attributes of a product against the schema of its category:
+  - a required attribute must have a value, a null value is removed
+  - an attribute which is not in the schema is refused, a product without category has no attributes
+*/
+func validateProductAttributes(db orm.DB, product *Product) error {
+	if product.Attributes == nil {
+		product.Attributes = make(map[string]interface{})
+	}
+
+	schema := make([]CategoryAttribute, 0)
+	if product.CategoryID != "" {
+		category := &Category{ID: product.CategoryID}
+		if err := db.Model(category).WherePK().Select(); err != nil {
+			if err == pg.ErrNoRows {
+				return apierrors.Validation(apierrors.FieldError{Field: "category_id", Code: apierrors.FieldNotFound, Message: "category_id not exists"})
+			}
+
+			return apierrors.FromDB(err, "have error when get category")
+		}
+		schema = category.AttributeSchema
+	}
+
+	apiErr := apierrors.Validation()
+	declared := make(map[string]bool)
+	for _, attribute := range schema {
+		declared[attribute.Name] = true
+		field := "attributes." + attribute.Name
+
+		value, ok := product.Attributes[attribute.Name]
+		if !ok || value == nil {
+			delete(product.Attributes, attribute.Name)
+			if attribute.Required {
+				apiErr.Fields = append(apiErr.Fields, apierrors.FieldError{Field: field, Code: apierrors.FieldRequired, Message: field + " is required"})
+			}
+			continue
+		}
+
+		if message := attribute.check(value); message != "" {
+			apiErr.Fields = append(apiErr.Fields, apierrors.FieldError{Field: field, Code: apierrors.FieldInvalid, Message: field + " " + message})
+		}
+	}
+
+	unknown := make([]string, 0)
+	for name := range product.Attributes {
+		if !declared[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		field := "attributes." + name
+		apiErr.Fields = append(apiErr.Fields, apierrors.FieldError{Field: field, Code: apierrors.FieldInvalid, Message: field + " is not an attribute of the category"})
+	}
+
+	if len(apiErr.Fields) > 0 {
+		return apiErr
+	}
+	return nil
+}
+
+// applyAttributeFilters adds the filters attributes[name]=value to a query on products, the values are compared as text
+func applyAttributeFilters(c *gin.Context, query *orm.Query) {
+	for name, value := range c.QueryMap("attributes") {
+		query.Where("product.attributes ->> ? = ?", name, value)
+	}
+}
+
+// @Summary      Get attribute schema of category
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Category ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/:id/attributes [get]
+func (h *ProductHandler) GetCategoryAttributes(c *gin.Context) {
+	category := &Category{ID: c.Param("id")}
+	if err := h.db.Model(category).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("category not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get category"))
+		return
+	}
+
+	attributes := category.AttributeSchema
+	if attributes == nil {
+		attributes = make([]CategoryAttribute, 0)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"category_id": category.ID,
+		"attributes":  attributes,
+	})
+}
+
+// @Summary      Replace attribute schema of category
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate, only for admins
+// @Description  type is string, number, enum or date (YYYY-MM-DD), an enum has the accepted values, e.g. {"name": "plug", "type": "enum", "values": ["EU", "UK"], "required": true}
+// @Description  The products are validated against the schema when they are created or updated, existing products are not checked
+// @Param        id  path  int  true  "Category ID"
+// @Param        request  body  CategoryAttributesRequest  true  "Attributes"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/:id/attributes [put]
+func (h *ProductHandler) UpdateCategoryAttributes(c *gin.Context) {
+	var req CategoryAttributesRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if req.Attributes == nil {
+		req.Attributes = make([]CategoryAttribute, 0)
+	}
+	names := make(map[string]bool)
+	for i, attribute := range req.Attributes {
+		field := fmt.Sprintf("attributes[%d]", i)
+		if names[attribute.Name] {
+			apierrors.Reply(c, apierrors.InvalidField(field+".name", fmt.Sprintf("attribute %v is defined twice", attribute.Name)))
+			return
+		}
+		names[attribute.Name] = true
+
+		if (attribute.Type == "enum") != (len(attribute.Values) > 0) {
+			apierrors.Reply(c, apierrors.InvalidField(field+".values", "values are required for an enum and only for an enum"))
+			return
+		}
+	}
+
+	category := &Category{ID: c.Param("id")}
+	if err := h.db.Model(category).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("category not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get category"))
+		return
+	}
+
+	before := *category
+	category.AttributeSchema = req.Attributes
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(category).Column("attribute_schema").WherePK().Update(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "update", Entity: "category", EntityID: category.ID}, before, category)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when update category"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":        "update category attributes successfully",
+		"attributes": category.AttributeSchema,
+	})
+}
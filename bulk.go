@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-pg/pg/v10"
+	"manage-products/apierrors"
+	"net/http"
+)
+
+// modes of a bulk request
+const (
+	bulkAllOrNothing = "all_or_nothing"
+	bulkBestEffort   = "best_effort"
+)
+
+// statuses of the result of a bulk operation
+const (
+	bulkSucceeded  = "succeeded"
+	bulkFailed     = "failed"
+	bulkRolledBack = "rolled_back"
+	bulkSkipped    = "skipped"
+)
+
+// @Summary      Create, update and delete products in one request
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The operations are applied in order in one transaction, op is create, update, delete or update_where
+// @Description  update and delete need the version of the product, like If-Match. update_where changes every product matching filter, e.g. {"op": "update_where", "filter": {"field": "supplier", "values": ["X"]}, "changes": {"status": "discontinued"}}
+// @Description  mode all_or_nothing (by default): nothing is applied if an operation fails, the problem has the results
+// @Description  mode best_effort: the failed operations are rolled back alone, the others are applied
+// @Param        request  body  ProductBulkRequest  true  "Operations"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/bulk [post]
+func (h *ProductHandler) BulkProducts(c *gin.Context) {
+	var req ProductBulkRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if req.Mode == "" {
+		req.Mode = bulkAllOrNothing
+	}
+
+	results := make([]ProductBulkResult, len(req.Operations))
+	for i, operation := range req.Operations {
+		results[i] = ProductBulkResult{Index: i, Op: operation.Op, Status: bulkSkipped}
+	}
+
+	// failedErr rolls back the transaction of an all or nothing request
+	var failedErr *apierrors.Error
+	failed := 0
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		for i, operation := range req.Operations {
+			// a savepoint lets a best effort request continue after an error, which aborts the transaction
+			if req.Mode == bulkBestEffort {
+				if _, err := tx.Exec("SAVEPOINT bulk_operation"); err != nil {
+					return err
+				}
+			}
+
+			err := h.applyBulkOperation(tx, c, operation, &results[i])
+			if err == nil {
+				results[i].Status = bulkSucceeded
+				if req.Mode == bulkBestEffort {
+					if _, err := tx.Exec("RELEASE SAVEPOINT bulk_operation"); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			failed++
+			results[i].Status = bulkFailed
+			status, problem := apierrors.ProblemOf(c, err)
+			results[i].Error = problem
+
+			if req.Mode == bulkAllOrNothing {
+				code, _ := problem["code"].(string)
+				failedErr = apierrors.New(status, code, fmt.Sprintf("operation %d failed, no operation was applied", i))
+				return failedErr
+			}
+			if _, err := tx.Exec("ROLLBACK TO SAVEPOINT bulk_operation"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if failedErr != nil {
+		for i := range results {
+			if results[i].Status == bulkSucceeded {
+				results[i].Status = bulkRolledBack
+			}
+		}
+
+		apierrors.Reply(c, failedErr.With("results", results))
+		return
+	}
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when apply bulk operations"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"mode":      req.Mode,
+		"succeeded": len(results) - failed,
+		"failed":    failed,
+		"results":   results,
+	})
+}
+
+// applyBulkOperation applies an operation in the transaction of the bulk request and fills its result
+func (h *ProductHandler) applyBulkOperation(tx *pg.Tx, c *gin.Context, operation ProductBulkOperation, result *ProductBulkResult) error {
+	switch operation.Op {
+	case "create":
+		if operation.Product == nil {
+			return apierrors.InvalidField("product", "product is required")
+		}
+		if err := binding.Validator.ValidateStruct(operation.Product); err != nil {
+			return apierrors.FromBinding(err)
+		}
+
+		product, err := h.insertProduct(tx, c, *operation.Product)
+		if err != nil {
+			return err
+		}
+		result.ID, result.Version = product.ID, product.Version
+		return nil
+
+	case "update", "delete":
+		if operation.Op == "update" && operation.Changes == nil {
+			return apierrors.InvalidField("changes", "changes is required")
+		}
+
+		product, err := lockBulkProduct(tx, operation)
+		if err != nil {
+			return err
+		}
+
+		if operation.Op == "update" {
+			err = h.saveProduct(tx, c, product, *operation.Changes, "update")
+		} else {
+			err = removeProduct(tx, c, product)
+		}
+		if err != nil {
+			return err
+		}
+		result.ID, result.Version = product.ID, product.Version
+		return nil
+
+	case "update_where":
+		ids, err := h.updateProductsWhere(tx, c, operation)
+		result.IDs = ids
+		return err
+	}
+
+	return apierrors.InvalidField("op", "op must be create, update, delete or update_where")
+}
+
+// lockBulkProduct selects the product of an update or a delete and checks its version
+func lockBulkProduct(tx *pg.Tx, operation ProductBulkOperation) (*Product, error) {
+	if operation.ID == "" {
+		return nil, apierrors.InvalidField("id", "id is required")
+	}
+	if operation.Version <= 0 {
+		return nil, apierrors.InvalidField("version", "version is required, it is the ETag of the product")
+	}
+
+	product := &Product{ID: operation.ID}
+	if err := tx.Model(product).WherePK().For("UPDATE").Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, apierrors.NotFound("product not found")
+		}
+
+		return nil, apierrors.FromDB(err, "have error when get product")
+	}
+
+	if operation.Version != product.Version {
+		return nil, errVersionMismatch().With("version", product.Version)
+	}
+	return product, nil
+}
+
+// updateProductsWhere applies the changes to every product matching the filter and returns their ids
+func (h *ProductHandler) updateProductsWhere(tx *pg.Tx, c *gin.Context, operation ProductBulkOperation) ([]string, error) {
+	if operation.Filter == nil {
+		return nil, apierrors.InvalidField("filter", "filter is required")
+	}
+	if err := binding.Validator.ValidateStruct(operation.Filter); err != nil {
+		return nil, apierrors.FromBinding(err)
+	}
+	if operation.Changes == nil {
+		return nil, apierrors.InvalidField("changes", "changes is required")
+	}
+	// every product would get the same reference
+	if operation.Changes.Reference != nil {
+		return nil, apierrors.InvalidField("reference", "reference can not be changed by update_where")
+	}
+
+	products := make([]Product, 0)
+	query := tx.Model(&products).Relation("Category").Relation("Supplier")
+	if err := applyProductFilter(query, operation.Filter.Field, operation.Filter.Values, operation.Filter.IncludeDescendants); err != nil {
+		return nil, err
+	}
+	if err := query.For("UPDATE OF product").Order("product.id").Select(); err != nil {
+		return nil, apierrors.FromDB(err, "have error when get products")
+	}
+
+	ids := make([]string, 0, len(products))
+	for i := range products {
+		product := &products[i]
+		// the relations are only loaded by the filter, they are not part of the history
+		product.Category, product.Supplier = nil, nil
+
+		if err := h.saveProduct(tx, c, product, *operation.Changes, "update"); err != nil {
+			if apiErr, ok := err.(*apierrors.Error); ok {
+				apiErr.With("product_id", product.ID)
+			}
+			return nil, err
+		}
+		ids = append(ids, product.ID)
+	}
+	return ids, nil
+}
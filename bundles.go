@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"net/http"
+	"time"
+)
+
+// orderComponents sorts the components of a bundle by product, used with Relation("Components", orderComponents)
+func orderComponents(q *orm.Query) (*orm.Query, error) {
+	return q.Order("product_id ASC"), nil
+}
+
+/*
+computeBundleAvailability sets the available number of every bundle:
+the minimum over its components of the stock of the product divided by the quantity of the component.
+A product in the trash has no stock.
+*/
+func computeBundleAvailability(db orm.DB, bundles []Bundle) error {
+	ids := make([]string, 0)
+	for _, bundle := range bundles {
+		for _, component := range bundle.Components {
+			ids = append(ids, component.ProductID)
+		}
+	}
+
+	stocks := make(map[string]int)
+	if len(ids) > 0 {
+		products := make([]Product, 0)
+		err := db.Model(&products).Column("id", "quantity").Where("id IN (?)", pg.In(uniqueIDs(ids))).Select()
+		if err != nil {
+			return err
+		}
+		for _, product := range products {
+			stocks[product.ID] = product.Quantity
+		}
+	}
+
+	for i := range bundles {
+		bundle := &bundles[i]
+		bundle.Available = 0
+		for j := range bundle.Components {
+			component := &bundle.Components[j]
+			component.Stock = stocks[component.ProductID]
+			component.Available = 0
+			if component.Stock > 0 {
+				component.Available = component.Stock / component.Quantity
+			}
+			if j == 0 || component.Available < bundle.Available {
+				bundle.Available = component.Available
+			}
+		}
+	}
+	return nil
+}
+
+// checkBundleRequest rejects a product listed twice and the products which do not exist
+func checkBundleRequest(db orm.DB, req BundleRequest) *apierrors.Error {
+	ids := make([]string, 0, len(req.Components))
+	seen := make(map[string]bool, len(req.Components))
+	for i, component := range req.Components {
+		if seen[component.ProductID] {
+			return apierrors.InvalidField(fmt.Sprintf("components[%d].product_id", i), "product is already a component of the bundle")
+		}
+		seen[component.ProductID] = true
+		ids = append(ids, component.ProductID)
+	}
+
+	return checkProductIDs(db, ids)
+}
+
+func insertBundleComponents(db orm.DB, bundle *Bundle, req BundleRequest) error {
+	bundle.Components = make([]BundleComponent, 0, len(req.Components))
+	for _, component := range req.Components {
+		bundle.Components = append(bundle.Components, BundleComponent{
+			BundleID:  bundle.ID,
+			ProductID: component.ProductID,
+			Quantity:  component.Quantity,
+		})
+	}
+
+	_, err := db.Model(&bundle.Components).Insert()
+	return err
+}
+
+// @Summary      Get bundles
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Every bundle with its components, available is the number of bundles the stock of the components allows
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /bundles [get]
+func (h *ProductHandler) GetBundles(c *gin.Context) {
+	bundles := make([]Bundle, 0)
+	err := h.db.Model(&bundles).Relation("Components", orderComponents).Order("reference ASC").Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get bundles"))
+		return
+	}
+
+	if err := computeBundleAvailability(h.db, bundles); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get stock of components"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bundles": bundles,
+	})
+}
+
+// @Summary      Get bundle
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Bundle ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /bundles/:id [get]
+func (h *ProductHandler) GetBundle(c *gin.Context) {
+	bundle, err := findBundle(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bundle": bundle,
+	})
+}
+
+// @Summary      Create bundle
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  A bundle has no stock, it is sold with POST /stock/movements which takes the stock of its components
+// @Param        request  body  BundleRequest  true  "Bundle"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /bundles [post]
+func (h *ProductHandler) CreateBundle(c *gin.Context) {
+	var req BundleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if err := checkBundleRequest(h.db, req); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	bundle := &Bundle{Reference: req.Reference, Name: req.Name, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(bundle).Insert(); err != nil {
+			return err
+		}
+		if err := insertBundleComponents(tx, bundle, req); err != nil {
+			return err
+		}
+
+		created, err := findBundle(tx, fmt.Sprint(bundle.ID))
+		if err != nil {
+			return err
+		}
+		bundle = created
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "bundle", EntityID: fmt.Sprint(bundle.ID)}, nil, bundle)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create bundle"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":    "create bundle successfully",
+		"bundle": bundle,
+	})
+}
+
+// @Summary      Replace bundle
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The reference, the name and the components are replaced
+// @Param        request  body  BundleRequest  true  "Bundle"
+// @Param        id  path  int  true  "Bundle ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /bundles/:id [put]
+func (h *ProductHandler) UpdateBundle(c *gin.Context) {
+	var req BundleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	before, err := findBundle(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	if err := checkBundleRequest(h.db, req); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	bundle := &Bundle{ID: before.ID, Reference: req.Reference, Name: req.Name, UpdatedAt: time.Now()}
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(bundle).Column("reference", "name", "updated_at").WherePK().Update(); err != nil {
+			return err
+		}
+		if _, err := tx.Model((*BundleComponent)(nil)).Where("bundle_id = ?", bundle.ID).Delete(); err != nil {
+			return err
+		}
+		if err := insertBundleComponents(tx, bundle, req); err != nil {
+			return err
+		}
+
+		updated, err := findBundle(tx, fmt.Sprint(bundle.ID))
+		if err != nil {
+			return err
+		}
+		bundle = updated
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "update", Entity: "bundle", EntityID: fmt.Sprint(bundle.ID)}, before, bundle)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when update bundle"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":    "update bundle successfully",
+		"bundle": bundle,
+	})
+}
+
+// @Summary      Delete bundle
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The component products are kept, the stock movements of its sales are kept without bundle
+// @Param        id  path  int  true  "Bundle ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /bundles/:id [delete]
+func (h *ProductHandler) DeleteBundle(c *gin.Context) {
+	bundle, err := findBundle(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(bundle).WherePK().Delete(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "bundle", EntityID: fmt.Sprint(bundle.ID)}, bundle, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when delete bundle"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "delete bundle successfully",
+	})
+}
+
+// findBundle selects a bundle with its components and their availability
+func findBundle(db orm.DB, id string) (*Bundle, error) {
+	bundles := make([]Bundle, 0, 1)
+	err := db.Model(&bundles).Where("bundle.id = ?", id).Relation("Components", orderComponents).Select()
+	if err != nil {
+		return nil, apierrors.FromDB(err, "have error when get bundle")
+	}
+	if len(bundles) == 0 {
+		return nil, apierrors.NotFound("bundle not found")
+	}
+
+	if err := computeBundleAvailability(db, bundles); err != nil {
+		return nil, apierrors.FromDB(err, "have error when get stock of components")
+	}
+	return &bundles[0], nil
+}
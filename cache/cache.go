@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Tags invalidated by the writes of the api
+const (
+	Products      = "products"
+	Categories    = "categories"
+	Suppliers     = "suppliers"
+	ExchangeRates = "exchange_rates"
+)
+
+// Entry is a cached response
+type Entry struct {
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header"`
+	Body         []byte      `json:"body"`
+	ETag         string      `json:"etag"`
+	LastModified time.Time   `json:"last_modified"`
+}
+
+/*
+Cache stores entries by key.
+  - An entry is removed when its ttl expires or when one of its tags is invalidated
+  - Get returns nil without error when the key is not cached
+  - A tag has a generation incremented by InvalidateTags, Set stores an entry only if the generations of its tags,
+    read by Generations before the data of the entry, did not change: a response read before a write is not
+    stored after the write invalidated it
+*/
+type Cache interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	Generations(ctx context.Context, tags ...string) (map[string]int64, error)
+	// Set stores entry with the tags of generations
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration, generations map[string]int64) error
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+/*
+New returns the cache configured by the environment:
+  - REDIS_URL: e.g. redis://localhost:6379/0, a redis cache shared by the instances of the api
+  - CACHE_SIZE: max number of entries of the in-process LRU used without REDIS_URL, 1000 by default
+*/
+func New() (Cache, error) {
+	if url := os.Getenv("REDIS_URL"); url != "" {
+		return NewRedis(url)
+	}
+
+	size := 1000
+	if value := os.Getenv("CACHE_SIZE"); value != "" {
+		var err error
+		if size, err = strconv.Atoi(value); err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid CACHE_SIZE %q", value)
+		}
+	}
+	return NewLRU(size), nil
+}
+
+// TTL is the time an entry is cached: CACHE_TTL_SECONDS or 5 minutes by default
+func TTL() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("CACHE_TTL_SECONDS")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 5 * time.Minute
+}
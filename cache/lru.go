@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process cache which evicts the least recently used entry when it is full
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	tags  map[string]map[string]struct{}
+	// generations of the tags which were invalidated, a missing tag is at the generation 0
+	generations map[string]int64
+}
+
+type lruItem struct {
+	key       string
+	entry     *Entry
+	expiresAt time.Time
+	tags      []string
+}
+
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:  size,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+		tags:  make(map[string]map[string]struct{}),
+
+		generations: make(map[string]int64),
+	}
+}
+
+func (l *LRU) Get(_ context.Context, key string) (*Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	element, ok := l.items[key]
+	if !ok {
+		return nil, nil
+	}
+
+	item := element.Value.(*lruItem)
+	if time.Now().After(item.expiresAt) {
+		l.remove(element)
+		return nil, nil
+	}
+
+	l.order.MoveToFront(element)
+	return item.entry, nil
+}
+
+func (l *LRU) Generations(_ context.Context, tags ...string) (map[string]int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	generations := make(map[string]int64, len(tags))
+	for _, tag := range tags {
+		generations[tag] = l.generations[tag]
+	}
+	return generations, nil
+}
+
+func (l *LRU) Set(_ context.Context, key string, entry *Entry, ttl time.Duration, generations map[string]int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tags := make([]string, 0, len(generations))
+	for tag, generation := range generations {
+		if l.generations[tag] != generation {
+			return nil
+		}
+		tags = append(tags, tag)
+	}
+
+	if element, ok := l.items[key]; ok {
+		l.remove(element)
+	}
+
+	item := &lruItem{key: key, entry: entry, expiresAt: time.Now().Add(ttl), tags: tags}
+	l.items[key] = l.order.PushFront(item)
+	for _, tag := range tags {
+		if l.tags[tag] == nil {
+			l.tags[tag] = make(map[string]struct{})
+		}
+		l.tags[tag][key] = struct{}{}
+	}
+
+	for l.order.Len() > l.size {
+		l.remove(l.order.Back())
+	}
+	return nil
+}
+
+func (l *LRU) InvalidateTags(_ context.Context, tags ...string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, tag := range tags {
+		l.generations[tag]++
+		for key := range l.tags[tag] {
+			if element, ok := l.items[key]; ok {
+				l.remove(element)
+			}
+		}
+		delete(l.tags, tag)
+	}
+	return nil
+}
+
+// remove must be called with the lock held
+func (l *LRU) remove(element *list.Element) {
+	item := element.Value.(*lruItem)
+	l.order.Remove(element)
+	delete(l.items, item.key)
+	for _, tag := range item.tags {
+		delete(l.tags[tag], item.key)
+		if len(l.tags[tag]) == 0 {
+			delete(l.tags, tag)
+		}
+	}
+}
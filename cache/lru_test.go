@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUSetAfterInvalidate(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(10)
+
+	stale, _ := lru.Generations(ctx, Products)
+	if err := lru.InvalidateTags(ctx, Products); err != nil {
+		t.Fatal(err)
+	}
+	if err := lru.Set(ctx, "GET /products", &Entry{}, time.Minute, stale); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := lru.Get(ctx, "GET /products"); entry != nil {
+		t.Fatal("an entry read before an invalidation is stored")
+	}
+
+	current, _ := lru.Generations(ctx, Products)
+	if err := lru.Set(ctx, "GET /products", &Entry{}, time.Minute, current); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := lru.Get(ctx, "GET /products"); entry == nil {
+		t.Fatal("an entry read after the invalidation is not stored")
+	}
+
+	if err := lru.InvalidateTags(ctx, Products); err != nil {
+		t.Fatal(err)
+	}
+	if entry, _ := lru.Get(ctx, "GET /products"); entry != nil {
+		t.Fatal("an invalidated entry is returned")
+	}
+}
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	lru := NewLRU(2)
+	generations, _ := lru.Generations(ctx, Categories)
+
+	for _, key := range []string{"a", "b"} {
+		if err := lru.Set(ctx, key, &Entry{}, time.Minute, generations); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lru.Get(ctx, "a")
+	if err := lru.Set(ctx, "c", &Entry{}, time.Minute, generations); err != nil {
+		t.Fatal(err)
+	}
+
+	if entry, _ := lru.Get(ctx, "b"); entry != nil {
+		t.Error("b is not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if entry, _ := lru.Get(ctx, key); entry == nil {
+			t.Errorf("%v is evicted", key)
+		}
+	}
+}
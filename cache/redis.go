@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/redis/go-redis/v9"
+	"strconv"
+	"time"
+)
+
+const redisPrefix = "manage-products:cache:"
+
+/*
+Redis is a cache shared by the instances of the api.
+  - An entry is a json string with a ttl
+  - A tag is a set of the keys of its entries, it expires with the last entry added to it
+  - The generation of a tag is a counter without ttl, Set watches it so an invalidation aborts the transaction
+*/
+type Redis struct {
+	client *redis.Client
+}
+
+func NewRedis(url string) (*Redis, error) {
+	opt, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{client: redis.NewClient(opt)}, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := r.client.Get(ctx, redisPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{}
+	if err = json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *Redis) Generations(ctx context.Context, tags ...string) (map[string]int64, error) {
+	return readGenerations(ctx, r.client, tags)
+}
+
+// readGenerations reads with client, the transaction of Set reads the generations it watches
+func readGenerations(ctx context.Context, client redis.Cmdable, tags []string) (map[string]int64, error) {
+	generations := make(map[string]int64, len(tags))
+	if len(tags) == 0 {
+		return generations, nil
+	}
+
+	keys := make([]string, len(tags))
+	for i, tag := range tags {
+		keys[i] = generationKey(tag)
+	}
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, tag := range tags {
+		generations[tag] = 0
+		if value, ok := values[i].(string); ok {
+			if generations[tag], err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return generations, nil
+}
+
+func (r *Redis) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration, generations map[string]int64) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tags := make([]string, 0, len(generations))
+	keys := make([]string, 0, len(generations))
+	for tag := range generations {
+		tags = append(tags, tag)
+		keys = append(keys, generationKey(tag))
+	}
+
+	err = r.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := readGenerations(ctx, tx, tags)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			if current[tag] != generations[tag] {
+				return nil
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, redisPrefix+key, data, ttl)
+			for _, tag := range tags {
+				pipe.SAdd(ctx, redisPrefix+"tag:"+tag, key)
+				pipe.Expire(ctx, redisPrefix+"tag:"+tag, ttl)
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	// a tag was invalidated since Generations, the entry is not stored
+	if err == redis.TxFailedErr {
+		return nil
+	}
+	return err
+}
+
+func (r *Redis) InvalidateTags(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		// incremented first, a Set which adds a key to the tag after SMembers is aborted
+		if err := r.client.Incr(ctx, generationKey(tag)).Err(); err != nil {
+			return err
+		}
+
+		tagKey := redisPrefix + "tag:" + tag
+		keys, err := r.client.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return err
+		}
+
+		deleted := []string{tagKey}
+		for _, key := range keys {
+			deleted = append(deleted, redisPrefix+key)
+		}
+		if err = r.client.Del(ctx, deleted...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func generationKey(tag string) string {
+	return redisPrefix + "generation:" + tag
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"net/http"
+	"strings"
+)
+
+// categorySubtreeQuery selects the ids of the categories named names and of all their descendants
+func categorySubtreeQuery(names []string) *orm.SafeQueryAppender {
+	return pg.SafeQuery(`
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE name IN (?)
+			UNION
+			SELECT child.id FROM categories child JOIN subtree ON child.parent_id = subtree.id
+		)
+		SELECT id FROM subtree`, pg.In(names))
+}
+
+// buildCategoryTree links the categories to their children and returns the roots, sorted by name
+func buildCategoryTree(categories []Category) []*Category {
+	byID := make(map[string]*Category, len(categories))
+	for i := range categories {
+		byID[categories[i].ID] = &categories[i]
+	}
+
+	roots := make([]*Category, 0)
+	for i := range categories {
+		category := &categories[i]
+		if parent, ok := byID[category.ParentID]; ok {
+			parent.Children = append(parent.Children, category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+	return roots
+}
+
+// walkCategoryTree calls visit on every category depth first, depth is 0 for the roots
+func walkCategoryTree(categories []*Category, depth int, visit func(category *Category, depth int)) {
+	for _, category := range categories {
+		visit(category, depth)
+		walkCategoryTree(category.Children, depth+1, visit)
+	}
+}
+
+// @Summary      Get tree of categories
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The root categories with their children, recursively
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/tree [get]
+func (h *ProductHandler) GetCategoryTree(c *gin.Context) {
+	categories := make([]Category, 0)
+	if err := h.db.Model(&categories).Order("name ASC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get categories"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": buildCategoryTree(categories),
+	})
+}
+
+// @Summary      Get children of category
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Category ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/:id/children [get]
+func (h *ProductHandler) GetCategoryChildren(c *gin.Context) {
+	if _, err := findCategory(h.db, c.Param("id")); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	children := make([]Category, 0)
+	if err := h.db.Model(&children).Where("parent_id = ?", c.Param("id")).Order("name ASC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get categories"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"categories": children,
+	})
+}
+
+// @Summary      Get ancestors of category
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The breadcrumbs from the root category to the category
+// @Param        id  path  int  true  "Category ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/:id/ancestors [get]
+func (h *ProductHandler) GetCategoryAncestors(c *gin.Context) {
+	breadcrumbs := make([]Category, 0)
+	_, err := h.db.Query(&breadcrumbs, `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, name, parent_id, 0 AS depth FROM categories WHERE id = ?
+			UNION ALL
+			SELECT parent.id, parent.name, parent.parent_id, ancestors.depth + 1
+			FROM categories parent JOIN ancestors ON parent.id = ancestors.parent_id
+		)
+		SELECT id, name, parent_id FROM ancestors ORDER BY depth DESC`, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get ancestors"))
+		return
+	}
+
+	if len(breadcrumbs) == 0 {
+		apierrors.Reply(c, apierrors.NotFound("category not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"breadcrumbs": breadcrumbs,
+	})
+}
+
+// @Summary      Move category
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate, only for admins
+// @Description  The category is moved with its subtree under parent_id, an empty parent_id makes it a root category
+// @Description  Reply 422 if parent_id is the category or one of its descendants
+// @Param        id  path  int  true  "Category ID"
+// @Param        request  body  CategoryMoveRequest  true  "New parent"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /categories/:id/move [post]
+func (h *ProductHandler) MoveCategory(c *gin.Context) {
+	var req CategoryMoveRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	var category *Category
+	var before Category
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// two concurrent moves could each be valid alone and create a cycle together
+		if _, err := tx.Exec("LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+			return apierrors.FromDB(err, "have error when lock categories")
+		}
+
+		var err error
+		if category, err = findCategory(tx, c.Param("id")); err != nil {
+			return err
+		}
+		before = *category
+
+		if req.ParentID != "" {
+			if _, err := findCategory(tx, req.ParentID); err != nil {
+				if apiErr, ok := err.(*apierrors.Error); ok && apiErr.Status == http.StatusNotFound {
+					return apierrors.Validation(apierrors.FieldError{Field: "parent_id", Code: apierrors.FieldNotFound, Message: "parent_id not exists"})
+				}
+				return err
+			}
+
+			var inSubtree bool
+			_, err := tx.QueryOne(pg.Scan(&inSubtree), `
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM categories WHERE id = ?
+					UNION
+					SELECT child.id FROM categories child JOIN subtree ON child.parent_id = subtree.id
+				)
+				SELECT EXISTS (SELECT 1 FROM subtree WHERE id::TEXT = ?)`, category.ID, req.ParentID)
+			if err != nil {
+				return apierrors.FromDB(err, "have error when check subtree")
+			}
+			if inSubtree {
+				return apierrors.InvalidField("parent_id", "parent_id can not be the category or one of its descendants")
+			}
+		}
+
+		category.ParentID = req.ParentID
+		if _, err := tx.Model(category).Column("parent_id").WherePK().Update(); err != nil {
+			return apierrors.FromDB(err, "have error when move category")
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "move", Entity: "category", EntityID: category.ID}, before, category)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when move category"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":      "move category successfully",
+		"category": category,
+	})
+}
+
+// statisticsCategoryRollup replies the products per category in tree order, the totals of the descendants are added to their ancestors
+func (h *ProductHandler) statisticsCategoryRollup(c *gin.Context) {
+	rows, _, err := h.runStatistics(c, statisticsRequest{Dimensions: []string{"category_id"}, Metrics: []string{"count"}})
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	statisticsComputedAt(c, rows)
+
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		// products without category are not counted
+		if row.CategoryID != nil {
+			counts[*row.CategoryID] = row.Count
+		}
+	}
+
+	categories := make([]Category, 0)
+	if err := h.db.Model(&categories).Order("name ASC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get categories"))
+		return
+	}
+
+	roots := buildCategoryTree(categories)
+	totals := make(map[string]int, len(categories))
+	var rollup func(category *Category) int
+	rollup = func(category *Category) int {
+		total := counts[category.ID]
+		for _, child := range category.Children {
+			total += rollup(child)
+		}
+		totals[category.ID] = total
+		return total
+	}
+	for _, root := range roots {
+		rollup(root)
+	}
+
+	rsp := make([]CategoryRollupResponse, 0, len(categories))
+	walkCategoryTree(roots, 0, func(category *Category, depth int) {
+		rsp = append(rsp, CategoryRollupResponse{
+			CategoryID:    category.ID,
+			CategoryName:  category.Name,
+			ParentID:      category.ParentID,
+			Depth:         depth,
+			TotalProducts: counts[category.ID],
+			RollupTotal:   totals[category.ID],
+		})
+	})
+
+	labels := make([]string, 0, len(rsp))
+	values := make([]float64, 0, len(rsp))
+	for _, item := range rsp {
+		labels = append(labels, strings.Repeat("  ", item.Depth)+item.CategoryName)
+		values = append(values, float64(item.RollupTotal))
+	}
+	if replyStatisticsChart(c, "Products per category (roll-up)", labels, values) {
+		return
+	}
+
+	c.JSON(http.StatusOK, rsp)
+}
+
+// findCategory selects a category, the error is a 404 if it does not exist
+func findCategory(db orm.DB, id string) (*Category, error) {
+	category := &Category{ID: id}
+	if err := db.Model(category).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, apierrors.NotFound("category not found")
+		}
+
+		return nil, apierrors.FromDB(err, "have error when get category")
+	}
+	return category, nil
+}
@@ -0,0 +1,169 @@
+package charts
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"strings"
+)
+
+const (
+	Bar = "bar"
+	Pie = "pie"
+
+	PNG = "png"
+	SVG = "svg"
+)
+
+// Chart is a bar or pie chart of one value per label
+type Chart struct {
+	Title  string
+	Kind   string
+	Labels []string
+	Values []float64
+	Width  int
+	Height int
+}
+
+var palette = []color.RGBA{
+	{R: 0x4e, G: 0x79, B: 0xa7, A: 0xff},
+	{R: 0xf2, G: 0x8e, B: 0x2b, A: 0xff},
+	{R: 0xe1, G: 0x57, B: 0x59, A: 0xff},
+	{R: 0x76, G: 0xb7, B: 0xb2, A: 0xff},
+	{R: 0x59, G: 0xa1, B: 0x4f, A: 0xff},
+	{R: 0xed, G: 0xc9, B: 0x48, A: 0xff},
+	{R: 0xb0, G: 0x7a, B: 0xa1, A: 0xff},
+	{R: 0xff, G: 0x9d, B: 0xa7, A: 0xff},
+	{R: 0x9c, G: 0x75, B: 0x5f, A: 0xff},
+	{R: 0xba, G: 0xb0, B: 0xac, A: 0xff},
+}
+
+var (
+	textColor = color.RGBA{R: 0x33, G: 0x33, B: 0x33, A: 0xff}
+	axisColor = color.RGBA{R: 0x99, G: 0x99, B: 0x99, A: 0xff}
+)
+
+func colorAt(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+// Render writes the chart in format (png or svg)
+func Render(chart Chart, format string, w io.Writer) error {
+	if chart.Width <= 0 {
+		chart.Width = 800
+	}
+	if chart.Height <= 0 {
+		chart.Height = 500
+	}
+	if chart.Kind != Pie {
+		chart.Kind = Bar
+	}
+
+	switch format {
+	case PNG:
+		return renderPNG(chart, w)
+	case SVG:
+		return renderSVG(chart, w)
+	}
+	return fmt.Errorf("unknown chart format %v", format)
+}
+
+// ContentType returns the content type of a chart format
+func ContentType(format string) string {
+	if format == SVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+func IsFormat(format string) bool {
+	return format == PNG || format == SVG
+}
+
+func (chart Chart) max() float64 {
+	max := 0.0
+	for _, value := range chart.Values {
+		if value > max {
+			max = value
+		}
+	}
+	return max
+}
+
+func (chart Chart) total() float64 {
+	total := 0.0
+	for _, value := range chart.Values {
+		if value > 0 {
+			total += value
+		}
+	}
+	return total
+}
+
+func formatValue(value float64) string {
+	s := fmt.Sprintf("%.2f", value)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// truncate shortens a label to max characters
+func truncate(label string, max int) string {
+	runes := []rune(label)
+	if len(runes) <= max {
+		return label
+	}
+	if max <= 1 {
+		return string(runes[:max])
+	}
+	return string(runes[:max-1]) + "…"
+}
+
+// layout is the geometry shared by the png and svg renderers
+type layout struct {
+	left, top, right, bottom float64
+}
+
+func (chart Chart) plotArea() layout {
+	return layout{
+		left:   60,
+		top:    50,
+		right:  float64(chart.Width) - 20,
+		bottom: float64(chart.Height) - 70,
+	}
+}
+
+// pieGeometry returns the center and radius of a pie, the legend is on the right
+func (chart Chart) pieGeometry() (float64, float64, float64) {
+	height := float64(chart.Height) - 70
+	radius := height / 2
+	if radius > float64(chart.Width)/4 {
+		radius = float64(chart.Width) / 4
+	}
+	return 20 + radius, 50 + height/2, radius
+}
+
+func (chart Chart) legendLabel(i int) string {
+	percent := 0.0
+	if total := chart.total(); total > 0 && chart.Values[i] > 0 {
+		percent = chart.Values[i] * 100 / total
+	}
+	return fmt.Sprintf("%v: %v (%.1f%%)", truncate(chart.Labels[i], 30), formatValue(chart.Values[i]), percent)
+}
+
+const yTicks = 5
+
+// niceMax rounds the max value up to 1, 2 or 5 times a power of ten, for readable axis ticks
+func niceMax(max float64) float64 {
+	if max <= 0 {
+		return 1
+	}
+
+	magnitude := math.Pow(10, math.Floor(math.Log10(max)))
+	for _, step := range []float64{1, 2, 5, 10} {
+		if max <= step*magnitude {
+			return step * magnitude
+		}
+	}
+	return 10 * magnitude
+}
@@ -0,0 +1,152 @@
+package charts
+
+import (
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"sync"
+)
+
+var (
+	fontOnce  sync.Once
+	fontFaces map[float64]font.Face
+)
+
+// face returns the go regular font at size, faces are parsed once
+func face(size float64) font.Face {
+	fontOnce.Do(func() {
+		fontFaces = make(map[float64]font.Face)
+		parsed, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			panic(err)
+		}
+		for _, s := range []float64{11, 16} {
+			f, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: s, DPI: 72, Hinting: font.HintingFull})
+			if err != nil {
+				panic(err)
+			}
+			fontFaces[s] = f
+		}
+	})
+	return fontFaces[size]
+}
+
+type canvas struct {
+	img *image.RGBA
+}
+
+func (cv canvas) rect(x0, y0, x1, y1 float64, c color.Color) {
+	r := image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x1)), int(math.Round(y1)))
+	draw.Draw(cv.img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// text draws s with its baseline at y, anchor is start, middle or end
+func (cv canvas) text(s string, x, y float64, size float64, anchor string) {
+	f := face(size)
+	width := float64(font.MeasureString(f, s)) / 64
+	switch anchor {
+	case "middle":
+		x -= width / 2
+	case "end":
+		x -= width
+	}
+
+	d := font.Drawer{
+		Dst:  cv.img,
+		Src:  image.NewUniform(textColor),
+		Face: f,
+		Dot:  fixed.P(int(math.Round(x)), int(math.Round(y))),
+	}
+	d.DrawString(s)
+}
+
+func (cv canvas) slice(cx, cy, radius, start, end float64, c color.Color) {
+	bounds := cv.img.Bounds()
+	z := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	z.MoveTo(float32(cx), float32(cy))
+
+	steps := int(math.Ceil((end-start)/(math.Pi/90))) + 1
+	for i := 0; i <= steps; i++ {
+		angle := start + (end-start)*float64(i)/float64(steps)
+		z.LineTo(float32(cx+radius*math.Cos(angle)), float32(cy+radius*math.Sin(angle)))
+	}
+	z.ClosePath()
+	z.Draw(cv.img, bounds, image.NewUniform(c), image.Point{})
+}
+
+func renderPNG(chart Chart, w io.Writer) error {
+	cv := canvas{img: image.NewRGBA(image.Rect(0, 0, chart.Width, chart.Height))}
+	draw.Draw(cv.img, cv.img.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+
+	cv.text(chart.Title, float64(chart.Width)/2, 28, 16, "middle")
+
+	if chart.Kind == Pie {
+		drawPiePNG(cv, chart)
+	} else {
+		drawBarsPNG(cv, chart)
+	}
+
+	return png.Encode(w, cv.img)
+}
+
+func drawBarsPNG(cv canvas, chart Chart) {
+	area := chart.plotArea()
+	max := niceMax(chart.max())
+
+	for i := 0; i <= yTicks; i++ {
+		value := max * float64(i) / yTicks
+		y := area.bottom - (area.bottom-area.top)*float64(i)/yTicks
+		cv.rect(area.left, y, area.right, y+1, axisColor)
+		cv.text(formatValue(value), area.left-6, y+4, 11, "end")
+	}
+
+	if len(chart.Values) == 0 {
+		return
+	}
+
+	slot := (area.right - area.left) / float64(len(chart.Values))
+	maxChars := int(slot / 7)
+	for i, value := range chart.Values {
+		x := area.left + slot*float64(i) + slot*0.15
+		height := 0.0
+		if max > 0 && value > 0 {
+			height = (area.bottom - area.top) * value / max
+		}
+		cv.rect(x, area.bottom-height, x+slot*0.7, area.bottom, colorAt(i))
+		cv.text(formatValue(value), x+slot*0.35, area.bottom-height-4, 11, "middle")
+		cv.text(truncate(chart.Labels[i], maxChars), x+slot*0.35, area.bottom+16, 11, "middle")
+	}
+}
+
+func drawPiePNG(cv canvas, chart Chart) {
+	cx, cy, radius := chart.pieGeometry()
+	total := chart.total()
+
+	start := -math.Pi / 2
+	for i, value := range chart.Values {
+		if total <= 0 || value <= 0 {
+			continue
+		}
+		end := start + 2*math.Pi*value/total
+		cv.slice(cx, cy, radius, start, end, colorAt(i))
+		start = end
+	}
+
+	legendX := cx + radius + 30
+	for i := range chart.Values {
+		y := 60 + float64(i)*20
+		if y > float64(chart.Height)-20 {
+			break
+		}
+		cv.rect(legendX, y, legendX+12, y+12, colorAt(i))
+		cv.text(chart.legendLabel(i), legendX+18, y+11, 11, "start")
+	}
+}
@@ -0,0 +1,126 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+)
+
+type svgWriter struct {
+	buf bytes.Buffer
+}
+
+func (sw *svgWriter) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&sw.buf, format, args...)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func (sw *svgWriter) rect(x0, y0, x1, y1 float64, c color.RGBA) {
+	sw.printf(`<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%v"/>`+"\n", x0, y0, x1-x0, y1-y0, hex(c))
+}
+
+func (sw *svgWriter) text(s string, x, y float64, size float64, anchor string) {
+	sw.printf(`<text x="%.1f" y="%.1f" font-size="%v" text-anchor="%v" fill="%v">%v</text>`+"\n",
+		x, y, size, anchor, hex(textColor), escape(s))
+}
+
+func renderSVG(chart Chart, w io.Writer) error {
+	sw := &svgWriter{}
+	sw.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" font-family="sans-serif">`+"\n",
+		chart.Width, chart.Height, chart.Width, chart.Height)
+	sw.printf(`<rect width="100%%" height="100%%" fill="#ffffff"/>` + "\n")
+	sw.text(chart.Title, float64(chart.Width)/2, 28, 16, "middle")
+
+	if chart.Kind == Pie {
+		drawPieSVG(sw, chart)
+	} else {
+		drawBarsSVG(sw, chart)
+	}
+
+	sw.printf("</svg>\n")
+	_, err := w.Write(sw.buf.Bytes())
+	return err
+}
+
+func drawBarsSVG(sw *svgWriter, chart Chart) {
+	area := chart.plotArea()
+	max := niceMax(chart.max())
+
+	for i := 0; i <= yTicks; i++ {
+		value := max * float64(i) / yTicks
+		y := area.bottom - (area.bottom-area.top)*float64(i)/yTicks
+		sw.rect(area.left, y, area.right, y+1, axisColor)
+		sw.text(formatValue(value), area.left-6, y+4, 11, "end")
+	}
+
+	if len(chart.Values) == 0 {
+		return
+	}
+
+	slot := (area.right - area.left) / float64(len(chart.Values))
+	maxChars := int(slot / 7)
+	for i, value := range chart.Values {
+		x := area.left + slot*float64(i) + slot*0.15
+		height := 0.0
+		if max > 0 && value > 0 {
+			height = (area.bottom - area.top) * value / max
+		}
+		sw.rect(x, area.bottom-height, x+slot*0.7, area.bottom, colorAt(i))
+		sw.text(formatValue(value), x+slot*0.35, area.bottom-height-4, 11, "middle")
+		sw.text(truncate(chart.Labels[i], maxChars), x+slot*0.35, area.bottom+16, 11, "middle")
+	}
+}
+
+func drawPieSVG(sw *svgWriter, chart Chart) {
+	cx, cy, radius := chart.pieGeometry()
+	total := chart.total()
+
+	start := -math.Pi / 2
+	for i, value := range chart.Values {
+		if total <= 0 || value <= 0 {
+			continue
+		}
+
+		if value == total {
+			// an arc can not start and end at the same point
+			sw.printf(`<circle cx="%.1f" cy="%.1f" r="%.1f" fill="%v"/>`+"\n", cx, cy, radius, hex(colorAt(i)))
+			continue
+		}
+
+		end := start + 2*math.Pi*value/total
+		largeArc := 0
+		if end-start > math.Pi {
+			largeArc = 1
+		}
+		sw.printf(`<path d="M %.1f %.1f L %.1f %.1f A %.1f %.1f 0 %v 1 %.1f %.1f Z" fill="%v"/>`+"\n",
+			cx, cy,
+			cx+radius*math.Cos(start), cy+radius*math.Sin(start),
+			radius, radius, largeArc,
+			cx+radius*math.Cos(end), cy+radius*math.Sin(end),
+			hex(colorAt(i)),
+		)
+		start = end
+	}
+
+	legendX := cx + radius + 30
+	for i := range chart.Values {
+		y := 60 + float64(i)*20
+		if y > float64(chart.Height)-20 {
+			break
+		}
+		sw.rect(legendX, y, legendX+12, y+12, colorAt(i))
+		sw.text(chart.legendLabel(i), legendX+18, y+11, 11, "start")
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// @Summary      Get collections
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Every collection with its number of products, the products in the trash are not counted
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections [get]
+func (h *ProductHandler) GetCollections(c *gin.Context) {
+	collections := make([]Collection, 0)
+	if err := h.db.Model(&collections).Order("name ASC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get collections"))
+		return
+	}
+
+	counts := make([]struct {
+		CollectionID int64
+		Count        int
+	}, 0)
+	err := h.db.Model((*ProductCollection)(nil)).
+		ColumnExpr("product_collection.collection_id, count(*) AS count").
+		Join("JOIN products p ON p.id::TEXT = product_collection.product_id AND p.deleted_at IS NULL").
+		Group("product_collection.collection_id").
+		Select(&counts)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when count products of collections"))
+		return
+	}
+
+	productCounts := make(map[int64]int, len(counts))
+	for _, count := range counts {
+		productCounts[count.CollectionID] = count.Count
+	}
+	for i := range collections {
+		collections[i].ProductCount = productCounts[collections[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collections": collections,
+	})
+}
+
+// @Summary      Get collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The collection with its products in the order of the collection
+// @Param        id  path  int  true  "Collection ID"
+// @Param        currency  query  string  false  "Currency of the prices (e.g., EUR, USD)"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections/:id [get]
+func (h *ProductHandler) GetCollection(c *gin.Context) {
+	collection, err := findCollection(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	products := make([]Product, 0)
+	err = h.db.Model(&products).
+		Join("JOIN product_collections pc ON pc.product_id = product.id::TEXT").
+		Where("pc.collection_id = ?", collection.ID).
+		Relation("Category").Relation("Supplier").Relation("Tags", orderTags).
+		Order("pc.position ASC").
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get products of collection"))
+		return
+	}
+
+	if !h.convertProductsToRequestedCurrency(c, products) {
+		return
+	}
+
+	collection.ProductCount = len(products)
+	c.JSON(http.StatusOK, gin.H{
+		"collection": collection,
+		"products":   products,
+	})
+}
+
+// @Summary      Create collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Reply 409 if a collection has the name
+// @Param        request  body  CollectionRequest  true  "Collection"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections [post]
+func (h *ProductHandler) CreateCollection(c *gin.Context) {
+	var req CollectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	collection := &Collection{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if collection.Name == "" {
+		apierrors.Reply(c, apierrors.InvalidField("name", "name is required"))
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(collection).Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "collection", EntityID: fmt.Sprint(collection.ID)}, nil, collection)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create collection"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":        "create collection successfully",
+		"collection": collection,
+	})
+}
+
+// @Summary      Update collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Replace the name and the description, the products are kept
+// @Param        request  body  CollectionRequest  true  "Collection"
+// @Param        id  path  int  true  "Collection ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections/:id [put]
+func (h *ProductHandler) UpdateCollection(c *gin.Context) {
+	var req CollectionRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	collection, err := findCollection(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	before := *collection
+
+	collection.Name = strings.TrimSpace(req.Name)
+	collection.Description = req.Description
+	collection.UpdatedAt = time.Now()
+	if collection.Name == "" {
+		apierrors.Reply(c, apierrors.InvalidField("name", "name is required"))
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(collection).Column("name", "description", "updated_at").WherePK().Update(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "update", Entity: "collection", EntityID: fmt.Sprint(collection.ID)}, before, collection)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when update collection"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":        "update collection successfully",
+		"collection": collection,
+	})
+}
+
+// @Summary      Delete collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The products of the collection are kept
+// @Param        id  path  int  true  "Collection ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections/:id [delete]
+func (h *ProductHandler) DeleteCollection(c *gin.Context) {
+	collection, err := findCollection(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(collection).WherePK().Delete(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "collection", EntityID: fmt.Sprint(collection.ID)}, collection, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when delete collection"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "delete collection successfully",
+	})
+}
+
+// @Summary      Add products to collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The products are appended in the order of product_ids, the products already in the collection keep their position
+// @Param        request  body  ProductMembershipRequest  true  "Products"
+// @Param        id  path  int  true  "Collection ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections/:id/products [post]
+func (h *ProductHandler) AddCollectionProducts(c *gin.Context) {
+	collection, err := findCollection(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	ids, ok := bindMembershipRequest(c, h.db, true)
+	if !ok {
+		return
+	}
+
+	var added int
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// the lock serializes the additions, so the positions are not taken twice
+		if err := tx.Model(collection).WherePK().For("UPDATE").Select(); err != nil {
+			return err
+		}
+
+		var last int
+		err := tx.Model((*ProductCollection)(nil)).
+			ColumnExpr("COALESCE(MAX(position), 0)").
+			Where("collection_id = ?", collection.ID).
+			Select(pg.Scan(&last))
+		if err != nil {
+			return err
+		}
+
+		links := make([]ProductCollection, 0, len(ids))
+		for i, id := range ids {
+			links = append(links, ProductCollection{CollectionID: collection.ID, ProductID: id, Position: last + i + 1, AddedAt: time.Now()})
+		}
+
+		res, err := tx.Model(&links).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+		added = res.RowsAffected()
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "add_products", Entity: "collection", EntityID: fmt.Sprint(collection.ID)}, nil, gin.H{"product_ids": ids})
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when add products to collection"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":   "add products to collection successfully",
+		"added": added,
+	})
+}
+
+// @Summary      Remove products from collection
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        request  body  ProductMembershipRequest  true  "Products"
+// @Param        id  path  int  true  "Collection ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /collections/:id/products [delete]
+func (h *ProductHandler) RemoveCollectionProducts(c *gin.Context) {
+	collection, err := findCollection(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	ids, ok := bindMembershipRequest(c, h.db, false)
+	if !ok {
+		return
+	}
+
+	var res orm.Result
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err = tx.Model((*ProductCollection)(nil)).
+			Where("collection_id = ?", collection.ID).
+			Where("product_id IN (?)", pg.In(ids)).
+			Delete()
+		if err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "remove_products", Entity: "collection", EntityID: fmt.Sprint(collection.ID)}, gin.H{"product_ids": ids}, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when remove products from collection"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":     "remove products from collection successfully",
+		"removed": res.RowsAffected(),
+	})
+}
+
+func findCollection(db orm.DB, id string) (*Collection, error) {
+	collection := &Collection{}
+	if err := db.Model(collection).Where("id = ?", id).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, apierrors.NotFound("collection not found")
+		}
+
+		return nil, apierrors.FromDB(err, "have error when get collection")
+	}
+	return collection, nil
+}
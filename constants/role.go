@@ -0,0 +1,4 @@
+package constants
+
+// DefaultRole is the role given at sign up, admins are promoted directly in the users table
+const DefaultRole = "user"
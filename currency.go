@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// exchangeRates maps a currency to the value of 1 unit of it in the base currency
+type exchangeRates map[string]utils.Decimal
+
+// loadExchangeRates returns the latest rate of every currency known at date
+func loadExchangeRates(db orm.DB, date time.Time) (exchangeRates, error) {
+	rows := make([]ExchangeRate, 0)
+	err := db.Model(&rows).
+		DistinctOn("currency").
+		Where("rate_date <= ?", date.Format(time.DateOnly)).
+		Order("currency ASC", "rate_date DESC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+
+	rates := exchangeRates{utils.BaseCurrency(): utils.NewDecimalFromInt(1)}
+	for _, row := range rows {
+		rates[strings.TrimSpace(row.Currency)] = row.Rate
+	}
+	return rates, nil
+}
+
+// factor returns the multiplier converting an amount from a currency to another
+func (rates exchangeRates) factor(from, to string) (utils.Decimal, error) {
+	if from == to {
+		return utils.NewDecimalFromInt(1), nil
+	}
+
+	fromRate, ok := rates[from]
+	if !ok {
+		return utils.Decimal{}, fmt.Errorf("no exchange rate for %v", from)
+	}
+	toRate, ok := rates[to]
+	if !ok {
+		return utils.Decimal{}, fmt.Errorf("no exchange rate for %v", to)
+	}
+
+	return fromRate.Div(toRate), nil
+}
+
+// convert converts amount from a currency to another, rounded to the decimals of the target currency
+func (rates exchangeRates) convert(amount utils.Decimal, from, to string) (utils.Decimal, error) {
+	if from == to {
+		return amount, nil
+	}
+
+	factor, err := rates.factor(from, to)
+	if err != nil {
+		return utils.Decimal{}, err
+	}
+
+	return amount.Mul(factor).Round(utils.CurrencyExponent(to)), nil
+}
+
+// convertProducts replaces the price of the products and of their variants by their price in currency
+func (rates exchangeRates) convertProducts(products []Product, currency string) error {
+	for i := range products {
+		price, err := rates.convert(products[i].Price, products[i].Currency, currency)
+		if err != nil {
+			return err
+		}
+		for j := range products[i].Variants {
+			variant := &products[i].Variants[j]
+			if variant.Price == nil {
+				continue
+			}
+			variantPrice, err := rates.convert(*variant.Price, products[i].Currency, currency)
+			if err != nil {
+				return err
+			}
+			variant.Price = &variantPrice
+		}
+		products[i].Price = price
+		products[i].Currency = currency
+	}
+	return nil
+}
+
+/*
+rateToCurrency returns a sql expression of the exchange rate from the currency of a product to currency,
+to be used in aggregations on products or product_statistics.
+It returns a 400 *apierrors.Error if the currency of a product has no exchange rate.
+*/
+func (rates exchangeRates) rateToCurrency(db orm.DB, currency string) (*orm.SafeQueryAppender, error) {
+	productCurrencies := make([]string, 0)
+	err := db.Model(&Product{}).ColumnExpr("DISTINCT product.currency").Select(&productCurrencies)
+	if err != nil {
+		return nil, apierrors.FromDB(err, "have error when get currencies of products")
+	}
+
+	if len(productCurrencies) == 0 {
+		return pg.SafeQuery("NULL::NUMERIC"), nil
+	}
+
+	expr := "CASE product.currency"
+	args := make([]interface{}, 0)
+	for _, productCurrency := range productCurrencies {
+		productCurrency = strings.TrimSpace(productCurrency)
+		factor, err := rates.factor(productCurrency, currency)
+		if err != nil {
+			return nil, apierrors.InvalidParam("currency", err.Error())
+		}
+
+		expr += " WHEN ? THEN ?::NUMERIC"
+		args = append(args, productCurrency, factor)
+	}
+	expr += " END"
+
+	return pg.SafeQuery(expr, args...), nil
+}
+
+// defaultCurrency is the currency of a new product: the currency of its supplier, or the base currency
+func (h *ProductHandler) defaultCurrency(supplierID string) string {
+	if supplierID != "" {
+		supplier := &Supplier{ID: supplierID}
+		if err := h.db.Model(supplier).WherePK().Select(); err == nil && strings.TrimSpace(supplier.Currency) != "" {
+			return strings.TrimSpace(supplier.Currency)
+		}
+	}
+	return utils.BaseCurrency()
+}
+
+/*
+convertProductsToRequestedCurrency converts the prices if the query has a currency param.
+It replies 400 and returns false when the currency is invalid or has no exchange rate.
+*/
+func (h *ProductHandler) convertProductsToRequestedCurrency(c *gin.Context, products []Product) bool {
+	currency := strings.ToUpper(c.Query("currency"))
+	if currency == "" {
+		return true
+	}
+
+	if !utils.IsCurrency(currency) {
+		apierrors.Reply(c, apierrors.InvalidField("currency", "invalid currency"))
+		return false
+	}
+
+	rates, err := loadExchangeRates(h.db, time.Now())
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get exchange rates"))
+		return false
+	}
+
+	if err = rates.convertProducts(products, currency); err != nil {
+		apierrors.Reply(c, apierrors.InvalidParam("currency", err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// @Summary      Get exchange rates
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Latest rate of every currency at date, a rate is the value of 1 unit of the currency in the base currency
+// @Param        date  query  string  false  "Date of the rates (YYYY-MM-DD), today by default"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /currencies/rates [get]
+func (h *ProductHandler) GetExchangeRates(c *gin.Context) {
+	date := time.Now()
+	if c.Query("date") != "" {
+		var err error
+		date, err = parseDate(c.Query("date"))
+		if err != nil {
+			apierrors.Reply(c, apierrors.InvalidParam("date", "date must be RFC3339 or YYYY-MM-DD"))
+			return
+		}
+	}
+
+	rates, err := loadExchangeRates(h.db, date)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get exchange rates"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"base_currency": utils.BaseCurrency(),
+		"date":          date.Format(time.DateOnly),
+		"rates":         rates,
+	})
+}
+
+// @Summary      Create exchange rate
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Only for admin, the rate of the same currency and date is replaced
+// @Param        request  body  ExchangeRateRequest  true  "Exchange rate"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /currencies/rates [post]
+func (h *ProductHandler) CreateExchangeRate(c *gin.Context) {
+	var req ExchangeRateRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	req.Currency = strings.ToUpper(req.Currency)
+	if !utils.IsCurrency(req.Currency) {
+		apierrors.Reply(c, apierrors.InvalidField("currency", "invalid currency"))
+		return
+	}
+
+	if req.Rate.Sign() <= 0 {
+		apierrors.Reply(c, apierrors.InvalidField("rate", "rate must be positive"))
+		return
+	}
+
+	rateDate, err := time.Parse(time.DateOnly, req.RateDate)
+	if err != nil {
+		apierrors.Reply(c, apierrors.InvalidField("rate_date", "rate_date must be YYYY-MM-DD"))
+		return
+	}
+
+	rate := &ExchangeRate{
+		Currency:  req.Currency,
+		Rate:      req.Rate,
+		RateDate:  rateDate,
+		CreatedAt: time.Now(),
+	}
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Model(rate).
+			OnConflict("(currency, rate_date) DO UPDATE").
+			Set("rate = EXCLUDED.rate").
+			Insert()
+		if err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "exchange_rate", EntityID: req.Currency}, nil, rate)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create exchange rate"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "create exchange rate successfully",
+	})
+}
@@ -15,51 +15,93 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/statistics/products-per-category": {
-            "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Statistics products per category",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
-                        }
-                    }
-                }
-            }
-        },
-        "/api/statistics/products-per-supplier": {
-            "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Statistics products per supplier",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
-                        }
-                    }
-                }
-            }
-        },
-        "/distance": {
+        "/api/statistics": {
             "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nCalculate Distance from your location to a city",
-                "summary": "Calculate Distance",
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nGroup the products by dimensions and compute metrics per group\nDimensions: category, category_id, supplier, stock_city, status\nMetrics: count, sum_quantity, avg_quantity, sum_stock_value, avg_price, min_price, max_price\nStatistics are pre-aggregated and refreshed on every product write, computed_at is the time they were last computed",
+                "summary": "Statistics of products",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "City",
-                        "name": "city",
+                        "description": "Comma separated dimensions (category by default)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated metrics (count by default)",
+                        "name": "metrics",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated dimensions or metrics, prefixed by - for descending order (e.g., -count)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Field to filter by (e.g., supplier, category)",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Values of field",
+                        "name": "values",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "With field=category, match the subcategories too",
+                        "name": "include_descendants",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of tags, the products have every tag",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of collections, the products are in every collection",
+                        "name": "collections",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the amounts, base currency by default",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number, starts at 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of groups per page",
+                        "name": "perPage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (by default), png or svg",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chart of png and svg: bar (by default) or pie",
+                        "name": "chart",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Metric drawn in the chart, the first metric by default",
+                        "name": "chart_metric",
                         "in": "query"
                     }
                 ],
@@ -73,19 +115,43 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
             }
         },
-        "/products": {
+        "/api/statistics/products-added": {
             "get": {
-                "description": "Fetch products with pagination and filtering\nAdd \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Get products",
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nNumber of products added per interval and cumulative total of products at the end of each interval\nIntervals without products have a count of 0",
+                "summary": "Statistics products added over time",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Number of products per page",
-                        "name": "perPage",
+                        "type": "string",
+                        "description": "day, week or month (day by default)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (YYYY-MM-DD or RFC3339), today by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "One series per category, supplier, stock_city or status",
+                        "name": "split_by",
                         "in": "query"
                     },
                     {
@@ -102,8 +168,8 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "The last reference of previous page",
-                        "name": "last_reference",
+                        "description": "json (by default), png or svg, the chart shows the count of all series",
+                        "name": "format",
                         "in": "query"
                     }
                 ],
@@ -117,21 +183,38 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
-            },
-            "post": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Create product",
+            }
+        },
+        "/api/statistics/products-per-category": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nSame as /api/statistics?group_by=category\u0026metrics=count\nThe X-Computed-At header is the time the statistics were last computed\nWith rollup=true, every category of the tree has its depth and rollup_total, the products of the category and of its descendants",
+                "summary": "Statistics products per category",
                 "parameters": [
                     {
-                        "description": "Product filter request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/main.ProductCreateRequest"
-                        }
+                        "type": "boolean",
+                        "description": "Roll-up the totals at each level of the category tree",
+                        "name": "rollup",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (by default), png or svg",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chart of png and svg: bar (by default) or pie",
+                        "name": "chart",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -144,30 +227,32 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
             }
         },
-        "/products/:id": {
-            "put": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Update product",
+        "/api/statistics/products-per-supplier": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nSame as /api/statistics?group_by=supplier\u0026metrics=count\nWith the scorecard of the supplier over all its receipts, see /api/statistics/suppliers/:id/scorecard\nThe X-Computed-At header is the time the statistics were last computed",
+                "summary": "Statistics products per supplier",
                 "parameters": [
                     {
-                        "description": "Product filter request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/main.ProductUpdateRequest"
-                        }
+                        "type": "string",
+                        "description": "json (by default), png or svg",
+                        "name": "format",
+                        "in": "query"
                     },
                     {
-                        "type": "integer",
-                        "description": "Product ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "string",
+                        "description": "Chart of png and svg: bar (by default) or pie",
+                        "name": "chart",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -180,19 +265,51 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
-            },
-            "delete": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Delete product",
+            }
+        },
+        "/api/statistics/suppliers/:id/scorecard": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nPerformance of the supplier on the receipts of the period with a purchase order, in total and per interval\non_time_rate: share delivered at the latest on the expected date, avg_lead_time_days: days from order to receipt\nfill_rate: received / ordered quantity, price_variance: average of (paid - price list) / price list\nA metric is null without receipts to compute it",
+                "summary": "Scorecard of supplier",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Product ID",
+                        "description": "Supplier ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "day, week or month (month by default)",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start date (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End date (YYYY-MM-DD or RFC3339), today by default",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (by default), png or svg, the chart shows the on-time rate",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -205,14 +322,76 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
             }
         },
-        "/products/categories": {
+        "/api/statistics/valuation": {
             "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Get all categories of products",
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nQuantity, stock value (price x quantity) and price statistics of the products per group\ncomputed_at is the time the statistics were last computed",
+                "summary": "Statistics inventory valuation",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated dimensions: category, supplier, stock_city, status (category by default)",
+                        "name": "group_by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Field to filter by (e.g., supplier, category)",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Values of field",
+                        "name": "values",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "With field=category, match the subcategories too",
+                        "name": "include_descendants",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of tags, the products have every tag",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of collections, the products are in every collection",
+                        "name": "collections",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the amounts, base currency by default",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "json (by default), png or svg, the chart shows the stock value",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Chart of png and svg: bar (by default) or pie",
+                        "name": "chart",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -223,17 +402,2637 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
                     }
                 }
             }
         },
-        "/products/cities": {
+        "/audit": {
             "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Get all cities of products",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nOnly for admin, events are sorted from the newest",
+                "summary": "Get audit events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Email of the user who did the action",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Entity (e.g., product, user)",
+                        "name": "entity",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the entity",
+                        "name": "entity_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Action (e.g., create, update, delete)",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of time range (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of time range (RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of events per page",
+                        "name": "perPage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "The last id of previous page",
+                        "name": "last_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/bundles": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nEvery bundle with its components, available is the number of bundles the stock of the components allows",
+                "summary": "Get bundles",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nA bundle has no stock, it is sold with POST /stock/movements which takes the stock of its components",
+                "summary": "Create bundle",
+                "parameters": [
+                    {
+                        "description": "Bundle",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.BundleRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/bundles/:id": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get bundle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Bundle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe reference, the name and the components are replaced",
+                "summary": "Replace bundle",
+                "parameters": [
+                    {
+                        "description": "Bundle",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.BundleRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Bundle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe component products are kept, the stock movements of its sales are kept without bundle",
+                "summary": "Delete bundle",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Bundle ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/:id/ancestors": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe breadcrumbs from the root category to the category",
+                "summary": "Get ancestors of category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/:id/attributes": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get attribute schema of category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate, only for admins\ntype is string, number, enum or date (YYYY-MM-DD), an enum has the accepted values, e.g. {\"name\": \"plug\", \"type\": \"enum\", \"values\": [\"EU\", \"UK\"], \"required\": true}\nThe products are validated against the schema when they are created or updated, existing products are not checked",
+                "summary": "Replace attribute schema of category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Attributes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CategoryAttributesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/:id/children": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get children of category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/:id/move": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate, only for admins\nThe category is moved with its subtree under parent_id, an empty parent_id makes it a root category\nReply 422 if parent_id is the category or one of its descendants",
+                "summary": "Move category",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Category ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New parent",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CategoryMoveRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/categories/tree": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe root categories with their children, recursively",
+                "summary": "Get tree of categories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/collections": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nEvery collection with its number of products, the products in the trash are not counted",
+                "summary": "Get collections",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nReply 409 if a collection has the name",
+                "summary": "Create collection",
+                "parameters": [
+                    {
+                        "description": "Collection",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CollectionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/collections/:id": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe collection with its products in the order of the collection",
+                "summary": "Get collection",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Collection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the prices (e.g., EUR, USD)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nReplace the name and the description, the products are kept",
+                "summary": "Update collection",
+                "parameters": [
+                    {
+                        "description": "Collection",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.CollectionRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Collection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe products of the collection are kept",
+                "summary": "Delete collection",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Collection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/collections/:id/products": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe products are appended in the order of product_ids, the products already in the collection keep their position",
+                "summary": "Add products to collection",
+                "parameters": [
+                    {
+                        "description": "Products",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductMembershipRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Collection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Remove products from collection",
+                "parameters": [
+                    {
+                        "description": "Products",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductMembershipRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Collection ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/currencies/rates": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nLatest rate of every currency at date, a rate is the value of 1 unit of the currency in the base currency",
+                "summary": "Get exchange rates",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Date of the rates (YYYY-MM-DD), today by default",
+                        "name": "date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nOnly for admin, the rate of the same currency and date is replaced",
+                "summary": "Create exchange rate",
+                "parameters": [
+                    {
+                        "description": "Exchange rate",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ExchangeRateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/distance": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nCalculate Distance from your location to a city",
+                "summary": "Calculate Distance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "City",
+                        "name": "city",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/lots/recall": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe lots with the lot number, their products (in the trash too) and every stock movement of the lots, e.g. the sales to recall",
+                "summary": "Recall lot",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Lot number",
+                        "name": "lot_number",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Product of the lot, when lot numbers are not unique between products",
+                        "name": "product_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products": {
+            "get": {
+                "description": "Fetch products with pagination and filtering\nAdd \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of products per page",
+                        "name": "perPage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Field to filter by (e.g., supplier, category)",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Values of field",
+                        "name": "values",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "With field=category, match the subcategories too",
+                        "name": "include_descendants",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of tags, the products have every tag",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of collections, the products are in every collection",
+                        "name": "collections",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The last reference of previous page",
+                        "name": "last_reference",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the prices (e.g., EUR, USD)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nWithout reference, the next reference of the current period is generated, e.g. PROD-202401-029\nReply 409 with the existing product if the reference is taken",
+                "summary": "Create product",
+                "parameters": [
+                    {
+                        "description": "Product filter request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductCreateRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe ETag header of the response must be sent back in If-Match to update or delete the product\nWith as_of, the product is rebuilt from its history as it was at this time",
+                "summary": "Get product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Time of the point-in-time view (RFC3339)",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nEvery field is replaced, a missing field is cleared, use PATCH to change some fields\nSend the ETag of the product in If-Match, reply 412 if the product was modified since\nReply 409 with the existing product if the reference is taken",
+                "summary": "Replace product",
+                "parameters": [
+                    {
+                        "description": "Product filter request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductReplaceRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the product",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe product is moved to the trash, it can be restored with /products/:id/restore",
+                "summary": "Delete product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the product",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\napplication/merge-patch+json (RFC 7386): the fields of the body are changed, null clears a field, e.g. {\"category_id\": null}\napplication/json-patch+json (RFC 6902): the operations are applied to the fields of ProductReplaceRequest, e.g. [{\"op\": \"test\", \"path\": \"/price\", \"value\": 10}, {\"op\": \"remove\", \"path\": \"/supplier_id\"}]\nReply 409 if a test operation fails, 422 if an operation targets an unknown field\nSend the ETag of the product in If-Match, reply 412 if the product was modified since",
+                "consumes": [
+                    "application/merge-patch+json",
+                    "application/json-patch+json"
+                ],
+                "summary": "Patch product",
+                "parameters": [
+                    {
+                        "description": "Merge patch or JSON patch",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductReplaceRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the product",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/history": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nEvery version of the product, with the changed fields compared to the previous version",
+                "summary": "Get history of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/lots": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe lots in the order they are taken (FEFO), the stock received without lot is in no lot",
+                "summary": "Get lots of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Include the lots with no remaining quantity",
+                        "name": "empty",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/media": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe primary image is first, url downloads the file and thumbnail_url the thumbnail of an image",
+                "summary": "Get images and documents of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nImages: jpeg, png, gif, webp, a thumbnail is generated. Documents: pdf, docx, xlsx, csv, txt\nThe type is detected from the content of the file, reply 415 for another type and 413 above MEDIA_MAX_SIZE_MB\nThe first image of a product is its primary image",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "summary": "Upload image or document of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Image or document",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Make the image the primary image of the product",
+                        "name": "primary",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/media/:media_id": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Download image or document of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Media ID",
+                        "name": "media_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nWhen the primary image is deleted, the oldest other image becomes primary",
+                "summary": "Delete image or document of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Media ID",
+                        "name": "media_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/media/:media_id/primary": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Make image the primary image of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Media ID",
+                        "name": "media_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/media/:media_id/thumbnail": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe image scaled to fit in 256x256, documents have no thumbnail",
+                "summary": "Download thumbnail of image of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Media ID",
+                        "name": "media_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/prices": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nAll price changes sorted by effective date, scheduled changes have no applied_at\nWith date, only the price of the product at this date is returned, a scheduled change counts once it is applied",
+                "summary": "Get price timeline of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Date of the price (RFC3339 or YYYY-MM-DD)",
+                        "name": "date",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe price is applied to the product automatically at effective_from, which must be in the future",
+                "summary": "Schedule price change",
+                "parameters": [
+                    {
+                        "description": "Price change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductPriceScheduleRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/prices/:price_id": {
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nOnly a price change which is not applied yet can be cancelled",
+                "summary": "Cancel scheduled price change",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Price change ID",
+                        "name": "price_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/purge": {
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nPermanently delete a product from the trash, only for admin",
+                "summary": "Purge product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/restore": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nMove a deleted product out of the trash",
+                "summary": "Restore product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/revert": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nUpdate the product with the values of a previous version, it creates a new version\nReply 422 if the product was deleted in the version, it must be restored from the trash",
+                "summary": "Revert product",
+                "parameters": [
+                    {
+                        "description": "Version to revert to",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductRevertRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the product",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/stock-movements": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe movements of the stock of the product, the latest first",
+                "summary": "Get stock movements of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "receipt, sale or adjustment",
+                        "name": "kind",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/suppliers": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe offers of the suppliers of the product, the preferred supplier first",
+                "summary": "Get suppliers of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nWithout currency, the cost is in the currency of the supplier. The first supplier of a product is its preferred supplier\nReply 409 if the supplier already supplies the product",
+                "summary": "Add supplier to product",
+                "parameters": [
+                    {
+                        "description": "Offer of the supplier",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductSupplierRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/suppliers/:supplier_id": {
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nReplace the sku, the cost, the minimum order quantity and the lead time of the supplier",
+                "summary": "Update supplier of product",
+                "parameters": [
+                    {
+                        "description": "Offer of the supplier",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductSupplierRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Supplier ID",
+                        "name": "supplier_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nWhen the preferred supplier is removed, the cheapest other supplier becomes preferred",
+                "summary": "Remove supplier from product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Supplier ID",
+                        "name": "supplier_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/suppliers/:supplier_id/preferred": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Make supplier the preferred supplier of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Supplier ID",
+                        "name": "supplier_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/suppliers/recommendation": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nRank the suppliers which accept an order of quantity units (minimum order quantity), by total cost or by lead time\nThe costs are converted to currency to be compared, a tie is broken by the other criteria then by the preferred supplier",
+                "summary": "Recommend supplier of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Quantity to order",
+                        "name": "quantity",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "cost (by default) or lead_time",
+                        "name": "by",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the total costs, the base currency by default",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/variants": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nA variant without price has the price of its product",
+                "summary": "Get variants of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the prices (e.g., EUR, USD)",
+                        "name": "currency",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\noptions are the values which make the variant, e.g. {\"size\": \"M\", \"colour\": \"red\"}, two variants of a product can not have the same options\nWithout reference, the reference of the product with the number of the variant is used, e.g. PROD-202401-029-01",
+                "summary": "Create variant of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Variant",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductVariantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/:id/variants/:variant_id": {
+            "put": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nSend the ETag of the variant in If-Match, reply 412 if the variant was modified since\nWithout reference, the variant keeps its reference",
+                "summary": "Replace variant of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Variant ID",
+                        "name": "variant_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the variant",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Variant",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductVariantRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Delete variant of product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Variant ID",
+                        "name": "variant_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the variant",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/bulk": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe operations are applied in order in one transaction, op is create, update, delete or update_where\nupdate and delete need the version of the product, like If-Match. update_where changes every product matching filter, e.g. {\"op\": \"update_where\", \"filter\": {\"field\": \"supplier\", \"values\": [\"X\"]}, \"changes\": {\"status\": \"discontinued\"}}\nmode all_or_nothing (by default): nothing is applied if an operation fails, the problem has the results\nmode best_effort: the failed operations are rolled back alone, the others are applied",
+                "summary": "Create, update and delete products in one request",
+                "parameters": [
+                    {
+                        "description": "Operations",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductBulkRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/categories": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get all categories of products",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/cities": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get all cities of products",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/expiring": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe products with lots expiring within the period, the lots already expired included, the earliest expiry first",
+                "summary": "Get expiring products",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Number of days, e.g. 30d (30d by default)",
+                        "name": "within",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Location of the lots",
+                        "name": "location",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/export": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Export products",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Field to filter by (e.g., supplier, category)",
+                        "name": "field",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Values of field",
+                        "name": "values",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "With field=category, match the subcategories too",
+                        "name": "include_descendants",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of tags, the products have every tag",
+                        "name": "tags",
+                        "in": "query"
+                    },
+                    {
+                        "type": "array",
+                        "description": "Names of collections, the products are in every collection",
+                        "name": "collections",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Currency of the prices (e.g., EUR, USD)",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated dimensions, a pie chart of products per dimension is drawn above the table",
+                        "name": "charts",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/suppliers": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get all suppliers of products",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/products/trash": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
+                "summary": "Get deleted products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Number of products per page",
+                        "name": "perPage",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "The last reference of previous page",
+                        "name": "last_reference",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/stock/movements": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nReceive, sell or adjust the stock (quantity) of a product, or sell a bundle which takes the stock of every component\nThe products are changed together or not at all, reply 409 insufficient_stock with the shortages if a stock would be negative\nA receipt can give the purchase order it delivers (receipt), see /api/statistics/suppliers/:id/scorecard\nAn incoming movement with lot adds to the lot, an outgoing movement takes from the lot, or from the lots not expired which expire first (FEFO)",
+                "summary": "Move stock",
+                "parameters": [
+                    {
+                        "description": "Stock movement",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.StockMovementRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags": {
+            "get": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nEvery tag with its number of products, the products in the trash are not counted",
+                "summary": "Get tags",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe name is lower cased, reply 409 if the tag exists",
+                "summary": "Create tag",
+                "parameters": [
+                    {
+                        "description": "Tag",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.TagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/:id": {
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nThe tag is removed from its products",
+                "summary": "Delete tag",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/tags/:id/products": {
+            "post": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nAdd the tag to every product of product_ids, the products already tagged are skipped",
+                "summary": "Tag products",
+                "parameters": [
+                    {
+                        "description": "Products",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductMembershipRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate\nRemove the tag from every product of product_ids",
+                "summary": "Untag products",
+                "parameters": [
+                    {
+                        "description": "Products",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/main.ProductMembershipRequest"
+                        }
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Tag ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/sign-in": {
+            "post": {
+                "description": "signin to get token to use api",
+                "summary": "SignIn",
+                "parameters": [
+                    {
+                        "description": "Login",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object",
+                                "additionalProperties": true
+                            }
+                        }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/sign-up": {
+            "post": {
+                "description": "create account for user to use api",
+                "summary": "SignUp",
+                "parameters": [
+                    {
+                        "description": "Create user request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.CreateUserRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
@@ -241,126 +3040,343 @@ const docTemplate = `{
                                 "additionalProperties": true
                             }
                         }
+                    },
+                    "default": {
+                        "description": "",
+                        "schema": {
+                            "$ref": "#/definitions/apierrors.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "apierrors.FieldError": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "apierrors.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string"
+                },
+                "detail": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/apierrors.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.BundleComponentRequest": {
+            "type": "object",
+            "required": [
+                "product_id",
+                "quantity"
+            ],
+            "properties": {
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                }
+            }
+        },
+        "main.BundleRequest": {
+            "type": "object",
+            "required": [
+                "components",
+                "name",
+                "reference"
+            ],
+            "properties": {
+                "components": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/main.BundleComponentRequest"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.CategoryAttribute": {
+            "type": "object",
+            "required": [
+                "name",
+                "type"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "string",
+                        "number",
+                        "enum",
+                        "date"
+                    ]
+                },
+                "values": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "main.CategoryAttributesRequest": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/main.CategoryAttribute"
+                    }
+                }
+            }
+        },
+        "main.CategoryMoveRequest": {
+            "type": "object",
+            "properties": {
+                "parent_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.CollectionRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 200
+                }
+            }
+        },
+        "main.ExchangeRateRequest": {
+            "type": "object",
+            "required": [
+                "currency",
+                "rate",
+                "rate_date"
+            ],
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "rate": {
+                    "type": "number"
+                },
+                "rate_date": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.ProductBulkFilter": {
+            "type": "object",
+            "required": [
+                "field",
+                "values"
+            ],
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "include_descendants": {
+                    "type": "boolean"
+                },
+                "values": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
                     }
                 }
             }
         },
-        "/products/export": {
-            "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Export products",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Field to filter by (e.g., supplier, category)",
-                        "name": "field",
-                        "in": "query"
-                    },
-                    {
-                        "type": "array",
-                        "description": "Values of field",
-                        "name": "values",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "file"
-                        }
+        "main.ProductBulkOperation": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "$ref": "#/definitions/main.ProductUpdateRequest"
+                },
+                "filter": {
+                    "$ref": "#/definitions/main.ProductBulkFilter"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "op": {
+                    "type": "string"
+                },
+                "product": {
+                    "$ref": "#/definitions/main.ProductCreateRequest"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ProductBulkRequest": {
+            "type": "object",
+            "required": [
+                "operations"
+            ],
+            "properties": {
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "all_or_nothing",
+                        "best_effort"
+                    ]
+                },
+                "operations": {
+                    "type": "array",
+                    "maxItems": 1000,
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/main.ProductBulkOperation"
                     }
                 }
             }
         },
-        "/products/suppliers": {
-            "get": {
-                "description": "Add \"Authorization: Bearer {your_token}\" in headers to authenticate",
-                "summary": "Get all suppliers of products",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
-                        }
-                    }
+        "main.ProductCreateRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "category_id": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "stock_city": {
+                    "type": "string"
+                },
+                "supplier_id": {
+                    "type": "string"
                 }
             }
         },
-        "/users/sign-in": {
-            "post": {
-                "description": "signin to get token to use api",
-                "summary": "SignIn",
-                "parameters": [
-                    {
-                        "description": "Login",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.LoginRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
-                        }
+        "main.ProductMembershipRequest": {
+            "type": "object",
+            "required": [
+                "product_ids"
+            ],
+            "properties": {
+                "product_ids": {
+                    "type": "array",
+                    "maxItems": 1000,
+                    "minItems": 1,
+                    "items": {
+                        "type": "string"
                     }
                 }
             }
         },
-        "/users/sign-up": {
-            "post": {
-                "description": "create account for user to use api",
-                "summary": "SignUp",
-                "parameters": [
-                    {
-                        "description": "Create user request",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/models.CreateUserRequest"
-                        }
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "type": "object",
-                                "additionalProperties": true
-                            }
-                        }
-                    }
+        "main.ProductPriceScheduleRequest": {
+            "type": "object",
+            "required": [
+                "effective_from"
+            ],
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "effective_from": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number"
                 }
             }
-        }
-    },
-    "definitions": {
-        "main.ProductCreateRequest": {
+        },
+        "main.ProductReplaceRequest": {
             "type": "object",
             "required": [
                 "name",
                 "reference"
             ],
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
                 "category_id": {
                     "type": "string"
                 },
+                "currency": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -384,12 +3400,56 @@ const docTemplate = `{
                 }
             }
         },
+        "main.ProductRevertRequest": {
+            "type": "object",
+            "required": [
+                "version"
+            ],
+            "properties": {
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "main.ProductSupplierRequest": {
+            "type": "object",
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "lead_time_days": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "min_order_quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "supplier_id": {
+                    "type": "string"
+                },
+                "unit_cost": {
+                    "type": "number",
+                    "minimum": 0
+                }
+            }
+        },
         "main.ProductUpdateRequest": {
             "type": "object",
             "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
                 "category_id": {
                     "type": "string"
                 },
+                "currency": {
+                    "type": "string"
+                },
                 "name": {
                     "type": "string"
                 },
@@ -413,6 +3473,122 @@ const docTemplate = `{
                 }
             }
         },
+        "main.ProductVariantRequest": {
+            "type": "object",
+            "required": [
+                "options"
+            ],
+            "properties": {
+                "options": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "price": {
+                    "type": "number"
+                },
+                "quantity": {
+                    "type": "integer",
+                    "minimum": 0
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.StockLotRequest": {
+            "type": "object",
+            "required": [
+                "lot_number"
+            ],
+            "properties": {
+                "expiry_date": {
+                    "type": "string"
+                },
+                "lot_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "main.StockMovementRequest": {
+            "type": "object",
+            "required": [
+                "kind",
+                "quantity"
+            ],
+            "properties": {
+                "bundle_id": {
+                    "type": "integer"
+                },
+                "kind": {
+                    "type": "string",
+                    "enum": [
+                        "receipt",
+                        "sale",
+                        "adjustment"
+                    ]
+                },
+                "location": {
+                    "type": "string"
+                },
+                "lot": {
+                    "$ref": "#/definitions/main.StockLotRequest"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "product_id": {
+                    "type": "string"
+                },
+                "quantity": {
+                    "type": "integer"
+                },
+                "receipt": {
+                    "$ref": "#/definitions/main.StockReceiptRequest"
+                }
+            }
+        },
+        "main.StockReceiptRequest": {
+            "type": "object",
+            "required": [
+                "ordered_at",
+                "supplier_id"
+            ],
+            "properties": {
+                "currency": {
+                    "type": "string"
+                },
+                "expected_at": {
+                    "type": "string"
+                },
+                "ordered_at": {
+                    "type": "string"
+                },
+                "ordered_quantity": {
+                    "type": "integer",
+                    "minimum": 1
+                },
+                "supplier_id": {
+                    "type": "string"
+                },
+                "unit_cost": {
+                    "type": "number"
+                }
+            }
+        },
+        "main.TagRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                }
+            }
+        },
         "models.CreateUserRequest": {
             "type": "object",
             "required": [
@@ -429,9 +3605,6 @@ const docTemplate = `{
                 },
                 "password": {
                     "type": "string"
-                },
-                "role": {
-                    "type": "string"
                 }
             }
         },
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// anyVersion is returned by ifMatchVersion when the client sent "If-Match: *"
+const anyVersion = -1
+
+func productETag(product *Product) string {
+	return fmt.Sprintf(`"%v"`, product.Version)
+}
+
+/*
+ifMatchVersion reads the version expected by the client from the If-Match header.
+  - The header is required for every write on a product, it replies 428 if missing
+  - It replies 400 if the header is not an ETag returned by this api
+*/
+func ifMatchVersion(c *gin.Context) (int, bool) {
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		apierrors.Reply(c, apierrors.New(http.StatusPreconditionRequired, apierrors.CodePreconditionRequired, "missing If-Match header"))
+		return 0, false
+	}
+
+	if ifMatch == "*" {
+		return anyVersion, true
+	}
+
+	ifMatch = strings.TrimPrefix(ifMatch, "W/")
+	version, err := strconv.Atoi(strings.Trim(ifMatch, `"`))
+	if err != nil || version <= 0 {
+		apierrors.Reply(c, apierrors.BadRequest("invalid If-Match header"))
+		return 0, false
+	}
+
+	return version, true
+}
+
+func errVersionMismatch() *apierrors.Error {
+	return apierrors.New(http.StatusPreconditionFailed, apierrors.CodeVersionMismatch, "product was modified by someone else")
+}
+
+// versionMismatch replies 412 with the current ETag of the product
+func versionMismatch(c *gin.Context, product *Product) {
+	c.Header("ETag", productETag(product))
+	apierrors.Reply(c, errVersionMismatch().With("version", product.Version))
+}
+
+// versionMismatchError is used when a conditional write matched no row: the product was modified or deleted concurrently
+func versionMismatchError(db orm.DB, id string) *apierrors.Error {
+	product := &Product{ID: id}
+	if err := db.Model(product).WherePK().Select(); err != nil {
+		return errVersionMismatch()
+	}
+
+	return errVersionMismatch().With("version", product.Version)
+}
+
+// replyProductError replies err, with the current ETag of the product when it is a version mismatch
+func replyProductError(c *gin.Context, err error) {
+	var apiErr *apierrors.Error
+	if errors.As(err, &apiErr) && apiErr.Code == apierrors.CodeVersionMismatch {
+		if version, ok := apiErr.Extensions["version"].(int); ok {
+			c.Header("ETag", productETag(&Product{Version: version}))
+		}
+	}
+
+	apierrors.Reply(c, err)
+}
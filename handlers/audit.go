@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/middlewares"
+	"manage-products/models"
+	"net/http"
+	"reflect"
+	"time"
+)
+
+type AuditHandler struct {
+	DB *pg.DB
+}
+
+/*
+RecordAudit saves an audit event for a write operation.
+  - event: Action, Entity and EntityID must be set, Actor is taken from the token if empty
+  - c: the request doing the write, nil for a background job
+  - before, after: the entity before and after the write (nil for a create or a delete), only changed fields are saved
+  - An error is logged and returned, a caller writing in a transaction must return it since the failed insert aborts the transaction
+*/
+func RecordAudit(db orm.DB, c *gin.Context, event models.AuditEvent, before, after interface{}) error {
+	if c != nil {
+		email, role := middlewares.CurrentUser(c)
+		if event.Actor == "" {
+			event.Actor = email
+		}
+		if event.ActorRole == "" {
+			event.ActorRole = role
+		}
+		event.ClientIP = c.ClientIP()
+		event.RequestID = c.GetString(middlewares.RequestIDKey)
+	}
+	event.Changes = DiffFields(before, after)
+	event.CreatedAt = time.Now()
+
+	if _, err := db.Model(&event).Insert(); err != nil {
+		fmt.Println(err)
+		return err
+	}
+	return nil
+}
+
+// DiffFields compares the json representation of before and after, and returns the fields having different values
+func DiffFields(before, after interface{}) map[string]models.FieldChange {
+	beforeFields := toJSONFields(before)
+	afterFields := toJSONFields(after)
+
+	changes := make(map[string]models.FieldChange)
+	for field, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[field]) {
+			changes[field] = models.FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if _, exists := beforeFields[field]; !exists && value != nil {
+			changes[field] = models.FieldChange{Before: nil, After: value}
+		}
+	}
+
+	return changes
+}
+
+func toJSONFields(v interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println(err)
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+
+	return fields
+}
+
+// @Summary      Get audit events
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Only for admin, events are sorted from the newest
+// @Param        actor  		query  string  false   "Email of the user who did the action"
+// @Param        entity  		query  string  false   "Entity (e.g., product, user)"
+// @Param        entity_id  	query  string  false   "ID of the entity"
+// @Param        action  		query  string  false   "Action (e.g., create, update, delete)"
+// @Param        from  			query  string  false   "Start of time range (RFC3339)"
+// @Param        to  			query  string  false   "End of time range (RFC3339)"
+// @Param        perPage  		query  int     false   "Number of events per page"
+// @Param        last_id  		query  int     false   "The last id of previous page"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /audit [get]
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	var req models.AuditRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if req.PerPage <= 0 {
+		req.PerPage = 50
+	}
+
+	events := make([]models.AuditEvent, 0)
+	query := h.DB.Model(&events)
+
+	if req.Actor != "" {
+		query.Where("actor = ?", req.Actor)
+	}
+	if req.Entity != "" {
+		query.Where("entity = ?", req.Entity)
+	}
+	if req.EntityID != "" {
+		query.Where("entity_id = ?", req.EntityID)
+	}
+	if req.Action != "" {
+		query.Where("action = ?", req.Action)
+	}
+	if req.From != "" {
+		from, err := time.Parse(time.RFC3339, req.From)
+		if err != nil {
+			apierrors.Reply(c, apierrors.InvalidField("from", "from must be RFC3339"))
+			return
+		}
+		query.Where("created_at >= ?", from)
+	}
+	if req.To != "" {
+		to, err := time.Parse(time.RFC3339, req.To)
+		if err != nil {
+			apierrors.Reply(c, apierrors.InvalidField("to", "to must be RFC3339"))
+			return
+		}
+		query.Where("created_at <= ?", to)
+	}
+	if req.LastID > 0 {
+		query.Where("id < ?", req.LastID)
+	}
+
+	err := query.Order("id DESC").Limit(req.PerPage).Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get audit events"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+	})
+}
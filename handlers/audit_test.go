@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"manage-products/models"
+	"reflect"
+	"testing"
+)
+
+type auditedEntity struct {
+	Name     string                 `json:"name"`
+	Price    float64                `json:"price"`
+	Tags     []string               `json:"tags"`
+	Extra    map[string]interface{} `json:"extra,omitempty"`
+	Password string                 `json:"-"`
+}
+
+func TestDiffFields(t *testing.T) {
+	entity := auditedEntity{Name: "pen", Price: 1.5, Tags: []string{"office"}, Password: "secret"}
+
+	tests := []struct {
+		name   string
+		before interface{}
+		after  interface{}
+		want   map[string]models.FieldChange
+	}{
+		{
+			name:   "no change",
+			before: entity,
+			after:  entity,
+			want:   map[string]models.FieldChange{},
+		},
+		{
+			name:   "changed fields only",
+			before: entity,
+			after:  auditedEntity{Name: "pencil", Price: 1.5, Tags: []string{"office", "school"}, Password: "other"},
+			want: map[string]models.FieldChange{
+				"name": {Before: "pen", After: "pencil"},
+				"tags": {Before: []interface{}{"office"}, After: []interface{}{"office", "school"}},
+			},
+		},
+		{
+			name:   "create",
+			before: nil,
+			after:  auditedEntity{Name: "pen"},
+			want: map[string]models.FieldChange{
+				"name":  {Before: nil, After: "pen"},
+				"price": {Before: nil, After: float64(0)},
+			},
+		},
+		{
+			name:   "delete",
+			before: &entity,
+			after:  nil,
+			want: map[string]models.FieldChange{
+				"name":  {Before: "pen", After: nil},
+				"price": {Before: 1.5, After: nil},
+				"tags":  {Before: []interface{}{"office"}, After: nil},
+			},
+		},
+		{
+			name:   "omitted field added",
+			before: entity,
+			after:  auditedEntity{Name: "pen", Price: 1.5, Tags: []string{"office"}, Extra: map[string]interface{}{"color": "blue"}},
+			want: map[string]models.FieldChange{
+				"extra": {Before: nil, After: map[string]interface{}{"color": "blue"}},
+			},
+		},
+		{
+			name:   "pointer and value are compared by their json",
+			before: &entity,
+			after:  entity,
+			want:   map[string]models.FieldChange{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffFields(tt.before, tt.after)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v\nwant %#v", got, tt.want)
+			}
+		})
+	}
+}
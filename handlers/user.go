@@ -1,9 +1,10 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v10"
+	"manage-products/apierrors"
 	"manage-products/constants"
 	"manage-products/models"
 	"manage-products/utils"
@@ -18,46 +19,39 @@ type UserHandler struct {
 // @Description  create account for user to use api
 // @Param        request  body  models.CreateUserRequest  true  "Create user request"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /users/sign-up [post]
 func (h *UserHandler) SignUp(c *gin.Context) {
 	var req models.CreateUserRequest
-	if err := c.Bind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-			"msg":   "invalid request body",
-		})
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
 		return
 	}
 
 	if len(req.Password) < 6 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "password must be at least 6 characters",
-		})
+		apierrors.Reply(c, apierrors.InvalidField("password", "password must be at least 6 characters"))
 		return
 	}
 
 	userExists := &models.User{} // equivalent to userExists := new(model.User)
 	err := h.DB.Model(userExists).Where("email = ?", req.Email).Select()
 	if err != nil && err.Error() != constants.ErrorNotFound {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get user",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get user"))
 		return
 	}
-	if userExists != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "email already exists",
+	if err == nil {
+		apierrors.Reply(c, &apierrors.Error{
+			Status: http.StatusConflict,
+			Code:   apierrors.CodeAlreadyExists,
+			Detail: "email already exists",
+			Fields: []apierrors.FieldError{{Field: "email", Code: apierrors.FieldAlreadyExists, Message: "email already exists"}},
 		})
 		return
 	}
 
 	password, err := utils.HashPassword(req.Password)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when hash password user",
-		})
+		apierrors.Reply(c, apierrors.Internal(err, "have error when hash password user"))
 		return
 	}
 
@@ -65,14 +59,18 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: password,
-		Role:     req.Role,
+		Role:     constants.DefaultRole,
 	}
-	_, err = h.DB.Model(&newUser).Insert()
+	err = h.DB.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(&newUser).Insert(); err != nil {
+			return err
+		}
+		return RecordAudit(tx, c, models.AuditEvent{
+			Actor: newUser.Email, ActorRole: newUser.Role, Action: "sign_up", Entity: "user", EntityID: newUser.ID,
+		}, nil, newUser)
+	})
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when create user",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create user"))
 		return
 	}
 
@@ -85,21 +83,17 @@ func (h *UserHandler) SignUp(c *gin.Context) {
 // @Description  signin to get token to use api
 // @Param        request  body  models.LoginRequest  true  "Login"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /users/sign-in [post]
 func (h *UserHandler) SignIn(c *gin.Context) {
 	var req models.LoginRequest
-	if err := c.Bind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-			"msg":   "invalid request body",
-		})
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
 		return
 	}
 
 	if len(req.Password) < 6 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "password must be at least 6 characters",
-		})
+		apierrors.Reply(c, apierrors.InvalidField("password", "password must be at least 6 characters"))
 		return
 	}
 
@@ -107,32 +101,31 @@ func (h *UserHandler) SignIn(c *gin.Context) {
 	err := h.DB.Model(user).Where("email = ?", req.Email).Select()
 	if err != nil {
 		if err.Error() == constants.ErrorNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"msg": "user not exists",
-			})
+			apierrors.Reply(c, apierrors.NotFound("user not exists"))
 			return
 		}
 
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get user",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get user"))
 		return
 	}
 
 	if !utils.VerifyPassword(user.Password, req.Password) {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "incorrect password",
-		})
+		RecordAudit(h.DB, c, models.AuditEvent{
+			Actor: user.Email, ActorRole: user.Role, Action: "sign_in_failed", Entity: "user", EntityID: user.ID,
+		}, nil, nil)
+		apierrors.Reply(c, apierrors.Unauthenticated("incorrect password"))
 		return
 	}
 
 	token, err := utils.GenerateToken(*user)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+		apierrors.Reply(c, apierrors.Internal(err, "could not generate token"))
 		return
 	}
 
+	RecordAudit(h.DB, c, models.AuditEvent{
+		Actor: user.Email, ActorRole: user.Role, Action: "sign_in", Entity: "user", EntityID: user.ID,
+	}, nil, nil)
+
 	c.JSON(http.StatusOK, gin.H{"token": token})
 }
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/middlewares"
+	"net/http"
+	"time"
+)
+
+// systemActor is the author of the changes done by background jobs
+const systemActor = "system"
+
+// recordProductVersion saves the product as a new version in its history, c is nil for a background job, an error is logged and returned
+func recordProductVersion(db orm.DB, c *gin.Context, action string, product *Product) error {
+	changedBy := systemActor
+	if c != nil {
+		changedBy, _ = middlewares.CurrentUser(c)
+	}
+
+	snapshot := *product
+	snapshot.Category = nil
+	snapshot.Supplier = nil
+	snapshot.Tags = nil
+	snapshot.Collections = nil
+
+	_, err := db.Model(&ProductVersion{
+		ProductID: product.ID,
+		Version:   product.Version,
+		Action:    action,
+		Snapshot:  snapshot,
+		ChangedBy: changedBy,
+		ChangedAt: product.UpdatedAt,
+	}).Insert()
+	if err != nil {
+		fmt.Println(err)
+	}
+	return err
+}
+
+// @Summary      Get history of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Every version of the product, with the changed fields compared to the previous version
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/history [get]
+func (h *ProductHandler) GetProductHistory(c *gin.Context) {
+	id := c.Param("id")
+	versions := make([]ProductVersion, 0)
+	err := h.db.Model(&versions).Where("product_id = ?", id).Order("version ASC").Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product history"))
+		return
+	}
+
+	if len(versions) == 0 {
+		apierrors.Reply(c, apierrors.NotFound("product not found"))
+		return
+	}
+
+	rsp := make([]ProductHistoryResponse, 0, len(versions))
+	for i, version := range versions {
+		var previous interface{}
+		if i > 0 {
+			previous = versions[i-1].Snapshot
+		}
+
+		rsp = append(rsp, ProductHistoryResponse{
+			ProductVersion: version,
+			Changes:        handlers.DiffFields(previous, version.Snapshot),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"history": rsp,
+	})
+}
+
+func (h *ProductHandler) getProductAsOf(c *gin.Context) {
+	asOf, err := time.Parse(time.RFC3339, c.Query("as_of"))
+	if err != nil {
+		apierrors.Reply(c, apierrors.InvalidParam("as_of", "as_of must be RFC3339"))
+		return
+	}
+
+	version := &ProductVersion{}
+	err = h.db.Model(version).
+		Where("product_id = ?", c.Param("id")).
+		Where("changed_at <= ?", asOf).
+		Order("version DESC").
+		Limit(1).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product did not exist at this time"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+	// the latest version is the deletion, the product was in the trash at this time
+	if version.Snapshot.DeletedAt != nil {
+		apierrors.Reply(c, apierrors.NotFound("product not found"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"product":    version.Snapshot,
+		"changed_at": version.ChangedAt,
+	})
+}
+
+// @Summary      Revert product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Update the product with the values of a previous version, it creates a new version
+// @Description  Reply 422 if the product was deleted in the version, it must be restored from the trash
+// @Param        request  body  ProductRevertRequest  true  "Version to revert to"
+// @Param        id  path  int  true  "Product ID"
+// @Param        If-Match  header  string  true  "ETag of the product"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/revert [post]
+func (h *ProductHandler) RevertProduct(c *gin.Context) {
+	var req ProductRevertRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	version := &ProductVersion{}
+	err := h.db.Model(version).
+		Where("product_id = ?", c.Param("id")).
+		Where("version = ?", req.Version).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("version not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product version"))
+		return
+	}
+	if version.Snapshot.DeletedAt != nil {
+		apierrors.Reply(c, apierrors.InvalidField("version", "the product was deleted in this version, it can not be reverted to it"))
+		return
+	}
+
+	h.updateProduct(c, "revert", func(product Product) (ProductUpdateRequest, error) {
+		return replaceRequestOf(version.Snapshot).changes(), nil
+	})
+}
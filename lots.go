@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxExpiringDays is the max period of GET /products/expiring
+const maxExpiringDays = 3650
+
+var withinPattern = regexp.MustCompile(`^(\d+)d?$`)
+
+// parseWithin reads the period of GET /products/expiring, a number of days with an optional d, e.g. 30d
+func parseWithin(value string) (int, *apierrors.Error) {
+	match := withinPattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, apierrors.InvalidParam("within", "within must be a number of days, e.g. 30d")
+	}
+	days, err := strconv.Atoi(match[1])
+	if err != nil || days > maxExpiringDays {
+		return 0, apierrors.InvalidParam("within", fmt.Sprintf("within must be at most %vd", maxExpiringDays))
+	}
+	return days, nil
+}
+
+// markExpired sets Expired on the lots whose expiry date is before today
+func markExpired(lots []StockLot) {
+	today := time.Now().Format(time.DateOnly)
+	for i := range lots {
+		lots[i].Expired = lots[i].ExpiryDate != nil && *lots[i].ExpiryDate < today
+	}
+}
+
+/*
+allocateLots moves the lots of a product by the quantity of a movement recorded in tx:
+  - an incoming movement with a lot adds to the lot, created if needed
+  - an outgoing movement takes from the lot of the request, or from the lots not expired which expire first (FEFO),
+    then from the stock received without lot
+*/
+func allocateLots(tx *pg.Tx, product *Product, movement *StockMovement, req StockMovementRequest) error {
+	if movement.Quantity > 0 {
+		if req.Lot == nil {
+			return nil
+		}
+		return receiveLot(tx, product, movement, req)
+	}
+
+	lots := make([]StockLot, 0)
+	query := tx.Model(&lots).
+		Where("product_id = ?", product.ID).
+		Where("quantity > 0").
+		OrderExpr("expiry_date ASC NULLS LAST, created_at ASC, id ASC").
+		For("UPDATE")
+	if req.Lot != nil {
+		query.Where("lot_number = ?", req.Lot.LotNumber)
+	} else {
+		query.Where("expiry_date IS NULL OR expiry_date >= CURRENT_DATE")
+	}
+	if req.Location != "" {
+		query.Where("location = ?", req.Location)
+	}
+
+	if err := query.Select(); err != nil {
+		return apierrors.FromDB(err, "have error when get lots")
+	}
+
+	remaining := -movement.Quantity
+	for i := range lots {
+		if remaining == 0 {
+			break
+		}
+
+		lot := &lots[i]
+		taken := lot.Quantity
+		if taken > remaining {
+			taken = remaining
+		}
+		remaining -= taken
+		lot.Quantity -= taken
+
+		_, err := tx.Model(lot).Set("quantity = ?quantity").Set("updated_at = NOW()").WherePK().Update()
+		if err != nil {
+			return apierrors.FromDB(err, "have error when update lot")
+		}
+		movement.Allocations = append(movement.Allocations, StockLotAllocation{
+			MovementID: movement.ID,
+			LotID:      lot.ID,
+			LotNumber:  lot.LotNumber,
+			Quantity:   -taken,
+		})
+	}
+
+	if remaining > 0 && req.Lot == nil && req.Location == "" {
+		// the stock received without lot is the stock which is in no lot, expired or not
+		var inLots int
+		err := tx.Model((*StockLot)(nil)).
+			ColumnExpr("COALESCE(SUM(quantity), 0)").
+			Where("product_id = ?", product.ID).
+			Select(pg.Scan(&inLots))
+		if err != nil {
+			return apierrors.FromDB(err, "have error when get lots")
+		}
+
+		// the lots taken above are already decremented, product.Quantity is already moved
+		withoutLot := product.Quantity + remaining - inLots
+		if withoutLot > 0 {
+			remaining -= min(withoutLot, remaining)
+		}
+	}
+
+	if remaining > 0 {
+		return apierrors.New(http.StatusConflict, apierrors.CodeInsufficientStock, "insufficient stock in the lots, expired lots are not taken").
+			With("shortages", []gin.H{{"product_id": product.ID, "lot": req.Lot, "location": req.Location, "missing": remaining}})
+	}
+
+	if len(movement.Allocations) > 0 {
+		if _, err := tx.Model(&movement.Allocations).Insert(); err != nil {
+			return apierrors.FromDB(err, "have error when record lots of stock movement")
+		}
+	}
+	return nil
+}
+
+// receiveLot adds an incoming movement to its lot, the expiry date of an existing lot is kept
+func receiveLot(tx *pg.Tx, product *Product, movement *StockMovement, req StockMovementRequest) error {
+	lot := &StockLot{
+		ProductID:        product.ID,
+		LotNumber:        req.Lot.LotNumber,
+		Location:         req.Location,
+		Quantity:         movement.Quantity,
+		ReceivedQuantity: movement.Quantity,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+	}
+	if lot.Location == "" {
+		lot.Location = product.StockCity
+	}
+	if req.Lot.ExpiryDate != "" {
+		lot.ExpiryDate = &req.Lot.ExpiryDate
+	}
+
+	_, err := tx.Model(lot).
+		OnConflict("(product_id, lot_number, location) DO UPDATE").
+		Set("quantity = stock_lot.quantity + EXCLUDED.quantity").
+		Set("received_quantity = stock_lot.received_quantity + EXCLUDED.received_quantity").
+		Set("expiry_date = COALESCE(stock_lot.expiry_date, EXCLUDED.expiry_date)").
+		Set("updated_at = EXCLUDED.updated_at").
+		Returning("*").
+		Insert()
+	if err != nil {
+		return apierrors.FromDB(err, "have error when receive lot")
+	}
+
+	movement.Allocations = []StockLotAllocation{{
+		MovementID: movement.ID,
+		LotID:      lot.ID,
+		LotNumber:  lot.LotNumber,
+		Quantity:   movement.Quantity,
+	}}
+	if _, err := tx.Model(&movement.Allocations).Insert(); err != nil {
+		return apierrors.FromDB(err, "have error when record lots of stock movement")
+	}
+	return nil
+}
+
+// @Summary      Get lots of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The lots in the order they are taken (FEFO), the stock received without lot is in no lot
+// @Param        id  path  int  true  "Product ID"
+// @Param        empty  query  bool  false  "Include the lots with no remaining quantity"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/lots [get]
+func (h *ProductHandler) GetProductLots(c *gin.Context) {
+	lots := make([]StockLot, 0)
+	query := h.db.Model(&lots).
+		Where("product_id = ?", c.Param("id")).
+		OrderExpr("expiry_date ASC NULLS LAST, created_at ASC, id ASC")
+	if c.Query("empty") != "true" {
+		query.Where("quantity > 0")
+	}
+
+	if err := query.Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get lots"))
+		return
+	}
+	markExpired(lots)
+
+	c.JSON(http.StatusOK, gin.H{
+		"lots": lots,
+	})
+}
+
+// @Summary      Get expiring products
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The products with lots expiring within the period, the lots already expired included, the earliest expiry first
+// @Param        within  query  string  false  "Number of days, e.g. 30d (30d by default)"
+// @Param        location  query  string  false  "Location of the lots"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/expiring [get]
+func (h *ProductHandler) GetExpiringProducts(c *gin.Context) {
+	days, apiErr := parseWithin(c.DefaultQuery("within", "30d"))
+	if apiErr != nil {
+		apierrors.Reply(c, apiErr)
+		return
+	}
+	until := time.Now().AddDate(0, 0, days).Format(time.DateOnly)
+
+	lots := make([]StockLot, 0)
+	query := h.db.Model(&lots).
+		Where("quantity > 0").
+		Where("expiry_date <= ?", until).
+		Order("expiry_date ASC", "id ASC")
+	if location := c.Query("location"); location != "" {
+		query.Where("location = ?", location)
+	}
+	if err := query.Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get expiring lots"))
+		return
+	}
+	markExpired(lots)
+
+	productsByID, err := selectProductsByID(h.db, lots, false)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get products"))
+		return
+	}
+
+	// the products are in the order of their first expiring lot, the products in the trash are skipped
+	rsp := make([]*ExpiringProduct, 0)
+	byProduct := make(map[string]*ExpiringProduct)
+	for _, lot := range lots {
+		product, ok := productsByID[lot.ProductID]
+		if !ok {
+			continue
+		}
+
+		expiring, ok := byProduct[lot.ProductID]
+		if !ok {
+			expiring = &ExpiringProduct{Product: product, Lots: make([]StockLot, 0)}
+			byProduct[lot.ProductID] = expiring
+			rsp = append(rsp, expiring)
+		}
+		expiring.ExpiringQuantity += lot.Quantity
+		expiring.Lots = append(expiring.Lots, lot)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"within_days": days,
+		"until":       until,
+		"products":    rsp,
+	})
+}
+
+// @Summary      Recall lot
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The lots with the lot number, their products (in the trash too) and every stock movement of the lots, e.g. the sales to recall
+// @Param        lot_number  query  string  true  "Lot number"
+// @Param        product_id  query  string  false  "Product of the lot, when lot numbers are not unique between products"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /lots/recall [get]
+func (h *ProductHandler) RecallLot(c *gin.Context) {
+	lotNumber := c.Query("lot_number")
+	if lotNumber == "" {
+		apierrors.Reply(c, apierrors.InvalidParam("lot_number", "lot_number is required"))
+		return
+	}
+
+	lots := make([]StockLot, 0)
+	query := h.db.Model(&lots).Where("lot_number = ?", lotNumber).Order("product_id ASC", "location ASC")
+	if productID := c.Query("product_id"); productID != "" {
+		query.Where("product_id = ?", productID)
+	}
+	if err := query.Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get lots"))
+		return
+	}
+	if len(lots) == 0 {
+		apierrors.Reply(c, apierrors.NotFound("lot not found"))
+		return
+	}
+	markExpired(lots)
+
+	lotIDs := make([]int64, 0, len(lots))
+	for _, lot := range lots {
+		lotIDs = append(lotIDs, lot.ID)
+	}
+
+	movements := make([]StockMovement, 0)
+	err := h.db.Model(&movements).
+		Where("id IN (SELECT movement_id FROM stock_lot_allocations WHERE lot_id IN (?))", pg.In(lotIDs)).
+		Relation("Allocations", func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("lot_id IN (?)", pg.In(lotIDs)), nil
+		}).
+		Order("created_at ASC", "id ASC").
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get stock movements of lot"))
+		return
+	}
+
+	if err := fillLotNumbers(h.db, movements); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get lots"))
+		return
+	}
+
+	productsByID, err := selectProductsByID(h.db, lots, true)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get products"))
+		return
+	}
+
+	products := make([]*Product, 0, len(productsByID))
+	for _, product := range productsByID {
+		products = append(products, product)
+	}
+	sort.Slice(products, func(i, j int) bool {
+		return products[i].ID < products[j].ID
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"lot_number": lotNumber,
+		"lots":       lots,
+		"products":   products,
+		"movements":  movements,
+	})
+}
+
+// selectProductsByID selects the products of the lots, with deleted the products in the trash too
+func selectProductsByID(db orm.DB, lots []StockLot, deleted bool) (map[string]*Product, error) {
+	productsByID := make(map[string]*Product)
+	if len(lots) == 0 {
+		return productsByID, nil
+	}
+
+	ids := make([]string, 0, len(lots))
+	for _, lot := range lots {
+		ids = append(ids, lot.ProductID)
+	}
+
+	products := make([]Product, 0)
+	query := db.Model(&products).Where("id IN (?)", pg.In(uniqueIDs(ids)))
+	if deleted {
+		query.AllWithDeleted()
+	}
+	if err := query.Select(); err != nil {
+		return nil, err
+	}
+
+	for i := range products {
+		productsByID[products[i].ID] = &products[i]
+	}
+	return productsByID, nil
+}
+
+// fillLotNumbers sets the lot number of the lots moved by the movements
+func fillLotNumbers(db orm.DB, movements []StockMovement) error {
+	ids := make([]int64, 0)
+	for _, movement := range movements {
+		for _, allocation := range movement.Allocations {
+			ids = append(ids, allocation.LotID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	lots := make([]StockLot, 0)
+	if err := db.Model(&lots).Column("id", "lot_number").Where("id IN (?)", pg.In(ids)).Select(); err != nil {
+		return err
+	}
+
+	lotNumbers := make(map[int64]string, len(lots))
+	for _, lot := range lots {
+		lotNumbers[lot.ID] = lot.LotNumber
+	}
+	for i := range movements {
+		for j := range movements[i].Allocations {
+			allocation := &movements[i].Allocations[j]
+			allocation.LotNumber = lotNumbers[allocation.LotID]
+		}
+	}
+	return nil
+}
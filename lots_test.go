@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseWithin(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "30d", want: 30},
+		{value: "7", want: 7},
+		{value: "0d", want: 0},
+		{value: "3650d", want: maxExpiringDays},
+		{value: "3651d", wantErr: true},
+		{value: "99999999999999999999d", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "d", wantErr: true},
+		{value: "-1d", wantErr: true},
+		{value: "30days", wantErr: true},
+		{value: "1w", wantErr: true},
+		{value: " 30d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseWithin(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				if err.Status != http.StatusBadRequest || len(err.Fields) != 1 || err.Fields[0].Field != "within" {
+					t.Errorf("got %+v, want a 400 on within", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarkExpired(t *testing.T) {
+	date := func(days int) *string {
+		value := time.Now().AddDate(0, 0, days).Format(time.DateOnly)
+		return &value
+	}
+
+	lots := []StockLot{
+		{LotNumber: "yesterday", ExpiryDate: date(-1)},
+		{LotNumber: "today", ExpiryDate: date(0)},
+		{LotNumber: "tomorrow", ExpiryDate: date(1)},
+		{LotNumber: "no expiry"},
+		// a lot read again is marked from its date, not from its previous mark
+		{LotNumber: "marked before", ExpiryDate: date(30), Expired: true},
+	}
+	markExpired(lots)
+
+	want := map[string]bool{"yesterday": true, "today": false, "tomorrow": false, "no expiry": false, "marked before": false}
+	for _, lot := range lots {
+		if lot.Expired != want[lot.LotNumber] {
+			t.Errorf("%v: got expired %v, want %v", lot.LotNumber, lot.Expired, want[lot.LotNumber])
+		}
+	}
+}
@@ -6,8 +6,12 @@ import (
 	"github.com/go-pg/pg/v10"
 	"github.com/joho/godotenv"
 	"github.com/umahmood/haversine"
+	"manage-products/cache"
 	"manage-products/handlers"
 	"manage-products/middlewares"
+	"manage-products/models"
+	"manage-products/storage"
+	"manage-products/utils"
 	"os"
 	"time"
 )
@@ -26,6 +30,7 @@ var cityCoordinates = map[string]haversine.Coord{
 
 func main() {
 	r := gin.Default()
+	r.Use(middlewares.RequestID)
 
 	err := godotenv.Load()
 	if err != nil {
@@ -48,89 +53,571 @@ func main() {
 
 	db := pg.Connect(opt)
 
-	productHandler := ProductHandler{db: db}
+	mediaStorage, err := storage.New()
+	if err != nil {
+		panic(err)
+	}
+
+	responseCache, err := cache.New()
+	if err != nil {
+		panic(err)
+	}
+	cacheTTL := cache.TTL()
+
+	startTrashPurger(db, responseCache, mediaStorage)
+
+	startPriceScheduler(db, responseCache)
+
+	startStatisticsRefresher(db, responseCache)
+
+	productHandler := ProductHandler{db: db, storage: mediaStorage}
 
 	userHandler := handlers.UserHandler{DB: db}
 
+	auditHandler := handlers.AuditHandler{DB: db}
+
+	// statistics depend on the names of categories and suppliers and on the exchange rates
+	statisticsCache := middlewares.CacheResponse(responseCache, cacheTTL, cache.Products, cache.Categories, cache.Suppliers, cache.ExchangeRates)
+
+	invalidateProducts := middlewares.InvalidateCache(responseCache, cache.Products)
+
 	r.POST("users/sign-up", userHandler.SignUp)
 
 	r.POST("users/sign-in", userHandler.SignIn)
 
 	r.GET("products", middlewares.AuthenticateMiddleware, productHandler.GetProducts)
 
-	r.GET("products/categories", middlewares.AuthenticateMiddleware, productHandler.GetCategories)
+	r.GET("products/categories", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Categories), productHandler.GetCategories)
+
+	r.GET("categories/tree", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Categories), productHandler.GetCategoryTree)
+
+	r.GET("categories/:id/children", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Categories), productHandler.GetCategoryChildren)
+
+	r.GET("categories/:id/ancestors", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Categories), productHandler.GetCategoryAncestors)
+
+	r.POST("categories/:id/move", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), middlewares.InvalidateCache(responseCache, cache.Categories, cache.Products), productHandler.MoveCategory)
+
+	r.GET("categories/:id/attributes", middlewares.AuthenticateMiddleware, productHandler.GetCategoryAttributes)
+
+	r.PUT("categories/:id/attributes", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), middlewares.InvalidateCache(responseCache, cache.Categories, cache.Products), productHandler.UpdateCategoryAttributes)
+
+	r.GET("tags", middlewares.AuthenticateMiddleware, productHandler.GetTags)
+
+	r.POST("tags", middlewares.AuthenticateMiddleware, productHandler.CreateTag)
+
+	r.DELETE("tags/:id", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), invalidateProducts, productHandler.DeleteTag)
 
-	r.GET("products/suppliers", middlewares.AuthenticateMiddleware, productHandler.GetSuppliers)
+	r.POST("tags/:id/products", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.AddTagProducts)
 
-	r.POST("products", middlewares.AuthenticateMiddleware, productHandler.CreateProduct)
+	r.DELETE("tags/:id/products", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.RemoveTagProducts)
 
-	r.PUT("products/:id", middlewares.AuthenticateMiddleware, productHandler.UpdateProduct)
+	r.GET("collections", middlewares.AuthenticateMiddleware, productHandler.GetCollections)
 
-	r.DELETE("products/:id", middlewares.AuthenticateMiddleware, productHandler.DeleteProduct)
+	r.POST("collections", middlewares.AuthenticateMiddleware, productHandler.CreateCollection)
 
-	r.GET("api/statistics/products-per-category", middlewares.AuthenticateMiddleware, productHandler.StatisticsProductsPerCategory)
+	r.GET("collections/:id", middlewares.AuthenticateMiddleware, productHandler.GetCollection)
 
-	r.GET("api/statistics/products-per-supplier", middlewares.AuthenticateMiddleware, productHandler.StatisticsProductsPerSupplier)
+	r.PUT("collections/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UpdateCollection)
+
+	r.DELETE("collections/:id", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), invalidateProducts, productHandler.DeleteCollection)
+
+	r.POST("collections/:id/products", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.AddCollectionProducts)
+
+	r.DELETE("collections/:id/products", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.RemoveCollectionProducts)
+
+	r.GET("bundles", middlewares.AuthenticateMiddleware, productHandler.GetBundles)
+
+	r.POST("bundles", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.CreateBundle)
+
+	r.GET("bundles/:id", middlewares.AuthenticateMiddleware, productHandler.GetBundle)
+
+	r.PUT("bundles/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UpdateBundle)
+
+	r.DELETE("bundles/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.DeleteBundle)
+
+	r.POST("stock/movements", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.CreateStockMovement)
+
+	r.GET("products/suppliers", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Suppliers), productHandler.GetSuppliers)
+
+	r.POST("products", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.CreateProduct)
+
+	r.POST("products/bulk", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.BulkProducts)
+
+	r.PUT("products/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UpdateProduct)
+
+	r.PATCH("products/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.PatchProduct)
+
+	r.DELETE("products/:id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.DeleteProduct)
+
+	r.GET("api/statistics/products-per-category", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.StatisticsProductsPerCategory)
+
+	r.GET("api/statistics/products-per-supplier", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.StatisticsProductsPerSupplier)
+
+	r.GET("api/statistics", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.GetStatistics)
+
+	r.GET("api/statistics/suppliers/:id/scorecard", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.StatisticsSupplierScorecard)
+
+	r.GET("api/statistics/valuation", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.StatisticsValuation)
+
+	r.GET("api/statistics/products-added", middlewares.AuthenticateMiddleware, statisticsCache, productHandler.StatisticsProductsAdded)
 
 	r.GET("products/export", middlewares.AuthenticateMiddleware, productHandler.ExportProduct)
 
+	r.GET("currencies/rates", middlewares.AuthenticateMiddleware, productHandler.GetExchangeRates)
+
+	r.POST("currencies/rates", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), middlewares.InvalidateCache(responseCache, cache.ExchangeRates), productHandler.CreateExchangeRate)
+
+	r.GET("audit", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), auditHandler.GetAuditEvents)
+
 	r.GET("/distance", middlewares.AuthenticateMiddleware, calculateDistance)
 
-	r.GET("products/cities", middlewares.AuthenticateMiddleware, productHandler.GetCities)
+	r.GET("products/cities", middlewares.AuthenticateMiddleware, middlewares.CacheResponse(responseCache, cacheTTL, cache.Products), productHandler.GetCities)
+
+	r.GET("products/trash", middlewares.AuthenticateMiddleware, productHandler.GetTrash)
+
+	r.GET("products/expiring", middlewares.AuthenticateMiddleware, productHandler.GetExpiringProducts)
+
+	r.GET("lots/recall", middlewares.AuthenticateMiddleware, productHandler.RecallLot)
+
+	r.GET("products/:id", middlewares.AuthenticateMiddleware, productHandler.GetProduct)
+
+	r.GET("products/:id/variants", middlewares.AuthenticateMiddleware, productHandler.GetProductVariants)
+
+	r.POST("products/:id/variants", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.CreateProductVariant)
+
+	r.PUT("products/:id/variants/:variant_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UpdateProductVariant)
+
+	r.DELETE("products/:id/variants/:variant_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.DeleteProductVariant)
+
+	r.GET("products/:id/media", middlewares.AuthenticateMiddleware, productHandler.GetProductMedia)
+
+	r.POST("products/:id/media", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UploadProductMedia)
+
+	r.GET("products/:id/media/:media_id", middlewares.AuthenticateMiddleware, productHandler.DownloadProductMedia)
+
+	r.GET("products/:id/media/:media_id/thumbnail", middlewares.AuthenticateMiddleware, productHandler.DownloadProductMediaThumbnail)
+
+	r.POST("products/:id/media/:media_id/primary", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.SetPrimaryProductMedia)
+
+	r.DELETE("products/:id/media/:media_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.DeleteProductMedia)
+
+	r.GET("products/:id/stock-movements", middlewares.AuthenticateMiddleware, productHandler.GetProductStockMovements)
+
+	r.GET("products/:id/suppliers", middlewares.AuthenticateMiddleware, productHandler.GetProductSuppliers)
+
+	r.GET("products/:id/suppliers/recommendation", middlewares.AuthenticateMiddleware, productHandler.RecommendProductSupplier)
+
+	r.POST("products/:id/suppliers", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.AddProductSupplier)
+
+	r.PUT("products/:id/suppliers/:supplier_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.UpdateProductSupplier)
+
+	r.POST("products/:id/suppliers/:supplier_id/preferred", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.SetPreferredProductSupplier)
+
+	r.DELETE("products/:id/suppliers/:supplier_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.DeleteProductSupplier)
+
+	r.GET("products/:id/lots", middlewares.AuthenticateMiddleware, productHandler.GetProductLots)
+
+	r.GET("products/:id/history", middlewares.AuthenticateMiddleware, productHandler.GetProductHistory)
+
+	r.GET("products/:id/prices", middlewares.AuthenticateMiddleware, productHandler.GetProductPrices)
+
+	r.POST("products/:id/prices", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.ScheduleProductPrice)
+
+	r.DELETE("products/:id/prices/:price_id", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.CancelProductPrice)
+
+	r.POST("products/:id/revert", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.RevertProduct)
+
+	r.POST("products/:id/restore", middlewares.AuthenticateMiddleware, invalidateProducts, productHandler.RestoreProduct)
+
+	r.DELETE("products/:id/purge", middlewares.AuthenticateMiddleware, middlewares.RequireRole("admin"), invalidateProducts, productHandler.PurgeProduct)
 
 	r.Run()
 }
 
 type Product struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	Reference  string    `json:"reference"`
-	AddedDate  time.Time `json:"added_date"`
-	Status     string    `json:"status"`
-	CategoryID string    `json:"category_id"`
-	Price      float64   `json:"price"`
-	StockCity  string    `json:"stock_city"`
-	SupplierID string    `json:"supplier_id"`
-	Quantity   int       `json:"quantity"`
-	Category   *Category `json:"category" pg:"rel:has-one"`
-	Supplier   *Supplier `json:"supplier" pg:"rel:has-one"`
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Reference   string                 `json:"reference"`
+	AddedDate   time.Time              `json:"added_date"`
+	Status      string                 `json:"status"`
+	CategoryID  string                 `json:"category_id"`
+	Price       utils.Decimal          `json:"price" swaggertype:"number" pg:",use_zero"`
+	Currency    string                 `json:"currency"`
+	StockCity   string                 `json:"stock_city"`
+	SupplierID  string                 `json:"supplier_id"`
+	Quantity    int                    `json:"quantity"`
+	Attributes  map[string]interface{} `json:"attributes" pg:"type:jsonb"`
+	Version     int                    `json:"version"`
+	UpdatedAt   time.Time              `json:"updated_at"`
+	DeletedAt   *time.Time             `json:"deleted_at,omitempty" pg:",soft_delete"`
+	Category    *Category              `json:"category" pg:"rel:has-one"`
+	Supplier    *Supplier              `json:"supplier" pg:"rel:has-one"`
+	Variants    []ProductVariant       `json:"variants,omitempty" pg:"rel:has-many"`
+	Tags        []Tag                  `json:"tags,omitempty" pg:"many2many:product_tags"`
+	Collections []Collection           `json:"collections,omitempty" pg:"many2many:product_collections"`
 }
 
+// ProductCreateRequest has no reference to generate the next one, see referenceFormat
 type ProductCreateRequest struct {
-	Name       string  `json:"name" binding:"required"`
-	Reference  string  `json:"reference" binding:"required"`
-	Status     string  `json:"status"`
-	CategoryID string  `json:"category_id"`
-	Price      float64 `json:"price"`
-	StockCity  string  `json:"stock_city"`
-	SupplierID string  `json:"supplier_id"`
-	Quantity   int     `json:"quantity"`
+	Name       string                 `json:"name" binding:"required"`
+	Reference  string                 `json:"reference"`
+	Status     string                 `json:"status"`
+	CategoryID string                 `json:"category_id"`
+	Price      utils.Decimal          `json:"price" swaggertype:"number"`
+	Currency   string                 `json:"currency"`
+	StockCity  string                 `json:"stock_city"`
+	SupplierID string                 `json:"supplier_id"`
+	Quantity   int                    `json:"quantity"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// ProductReplaceRequest is the body of PUT and the document patched by PATCH, a missing field is cleared
+type ProductReplaceRequest struct {
+	Name       string                 `json:"name" binding:"required"`
+	Reference  string                 `json:"reference" binding:"required"`
+	Status     string                 `json:"status"`
+	CategoryID string                 `json:"category_id"`
+	Price      utils.Decimal          `json:"price" swaggertype:"number"`
+	Currency   string                 `json:"currency"`
+	StockCity  string                 `json:"stock_city"`
+	SupplierID string                 `json:"supplier_id"`
+	Quantity   int                    `json:"quantity"`
+	Attributes map[string]interface{} `json:"attributes"`
 }
 
+// ProductUpdateRequest is the changes of a product, a nil field is kept
 type ProductUpdateRequest struct {
-	Name       *string  `json:"name"`
-	Reference  *string  `json:"reference"`
-	Status     *string  `json:"status"`
-	CategoryID *string  `json:"category_id"`
-	Price      *float64 `json:"price"`
-	StockCity  *string  `json:"stock_city"`
-	SupplierID *string  `json:"supplier_id"`
-	Quantity   *int     `json:"quantity"`
+	Name       *string                 `json:"name"`
+	Reference  *string                 `json:"reference"`
+	Status     *string                 `json:"status"`
+	CategoryID *string                 `json:"category_id"`
+	Price      *utils.Decimal          `json:"price" swaggertype:"number"`
+	Currency   *string                 `json:"currency"`
+	StockCity  *string                 `json:"stock_city"`
+	SupplierID *string                 `json:"supplier_id"`
+	Quantity   *int                    `json:"quantity"`
+	Attributes *map[string]interface{} `json:"attributes"`
+}
+
+// ProductBulkRequest is applied in one transaction, see BulkProducts
+type ProductBulkRequest struct {
+	Mode       string                 `json:"mode" binding:"omitempty,oneof=all_or_nothing best_effort"`
+	Operations []ProductBulkOperation `json:"operations" binding:"required,min=1,max=1000"`
+}
+
+/*
+ProductBulkOperation is an operation of a bulk request:
+  - create: Product is created
+  - update: Changes are applied to the product ID, Version is its ETag
+  - delete: the product ID is moved to the trash, Version is its ETag
+  - update_where: Changes are applied to every product matching Filter
+*/
+type ProductBulkOperation struct {
+	Op      string                `json:"op"`
+	ID      string                `json:"id"`
+	Version int                   `json:"version"`
+	Product *ProductCreateRequest `json:"product"`
+	Changes *ProductUpdateRequest `json:"changes"`
+	Filter  *ProductBulkFilter    `json:"filter"`
+}
+
+// ProductBulkFilter selects the products like the field and values parameters of GET /products
+type ProductBulkFilter struct {
+	Field              string   `json:"field" binding:"required"`
+	Values             []string `json:"values" binding:"required,min=1"`
+	IncludeDescendants bool     `json:"include_descendants"`
+}
+
+// ProductBulkResult is the result of an operation: succeeded, failed, rolled_back or skipped
+type ProductBulkResult struct {
+	Index   int      `json:"index"`
+	Op      string   `json:"op"`
+	Status  string   `json:"status"`
+	ID      string   `json:"id,omitempty"`
+	Version int      `json:"version,omitempty"`
+	IDs     []string `json:"ids,omitempty"`
+	Error   gin.H    `json:"error,omitempty" swaggertype:"object"`
+}
+
+// ProductVariant is a variant of a product which differs by its options, see migrations/009_product_variants.sql
+type ProductVariant struct {
+	ID        int64             `json:"id"`
+	ProductID string            `json:"product_id"`
+	Reference string            `json:"reference"`
+	Options   map[string]string `json:"options" pg:"type:jsonb"`
+	Price     *utils.Decimal    `json:"price" swaggertype:"number"`
+	Quantity  int               `json:"quantity" pg:",use_zero"`
+	Version   int               `json:"version"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// ProductVariantRequest creates or replaces a variant, without price the variant has the price of its product
+type ProductVariantRequest struct {
+	Reference string            `json:"reference"`
+	Options   map[string]string `json:"options" binding:"required,min=1"`
+	Price     *utils.Decimal    `json:"price" swaggertype:"number"`
+	Quantity  int               `json:"quantity" binding:"min=0"`
+}
+
+// ProductMedia is an image or a document of a product, the file is in the storage, see migrations/012_product_media.sql
+type ProductMedia struct {
+	tableName struct{} `pg:"product_media,alias:media"`
+
+	ID           int64     `json:"id"`
+	ProductID    string    `json:"product_id"`
+	Kind         string    `json:"kind"`
+	FileName     string    `json:"file_name"`
+	ContentType  string    `json:"content_type"`
+	Size         int64     `json:"size"`
+	StorageKey   string    `json:"-"`
+	ThumbnailKey string    `json:"-"`
+	IsPrimary    bool      `json:"is_primary" pg:",use_zero"`
+	UploadedBy   string    `json:"uploaded_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url" pg:"-"`
+	ThumbnailURL string    `json:"thumbnail_url,omitempty" pg:"-"`
+}
+
+// Tag is a free-form label of products, see migrations/013_tags_collections.sql
+type Tag struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	CreatedAt    time.Time `json:"created_at"`
+	ProductCount int       `json:"product_count" pg:"-"`
+}
+
+// ProductTag links a product to a tag
+type ProductTag struct {
+	TagID     int64
+	ProductID string
+}
+
+// TagRequest creates a tag, the name is trimmed and lower cased
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// Collection is a curated and ordered list of products, e.g. "summer sale"
+type Collection struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description" pg:",use_zero"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ProductCount int       `json:"product_count" pg:"-"`
+}
+
+// ProductCollection links a product to a collection, Position orders the products of the collection
+type ProductCollection struct {
+	CollectionID int64
+	ProductID    string
+	Position     int
+	AddedAt      time.Time
+}
+
+// CollectionRequest creates or replaces a collection
+type CollectionRequest struct {
+	Name        string `json:"name" binding:"required,max=200"`
+	Description string `json:"description"`
+}
+
+// ProductMembershipRequest adds products to or removes products from a tag or a collection
+type ProductMembershipRequest struct {
+	ProductIDs []string `json:"product_ids" binding:"required,min=1,max=1000"`
+}
+
+// Bundle is a kit sold as one item and made of component products, see migrations/014_bundles_stock_movements.sql
+type Bundle struct {
+	ID         int64             `json:"id"`
+	Reference  string            `json:"reference"`
+	Name       string            `json:"name"`
+	Components []BundleComponent `json:"components" pg:"rel:has-many"`
+	Available  int               `json:"available" pg:"-"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+}
+
+// BundleComponent is Quantity units of a product in a bundle, Available is the number of bundles its stock allows
+type BundleComponent struct {
+	BundleID  int64  `json:"-" pg:",pk"`
+	ProductID string `json:"product_id" pg:",pk"`
+	Quantity  int    `json:"quantity"`
+	Stock     int    `json:"stock" pg:"-"`
+	Available int    `json:"available" pg:"-"`
+}
+
+// BundleRequest creates or replaces a bundle with its components
+type BundleRequest struct {
+	Reference  string                   `json:"reference" binding:"required"`
+	Name       string                   `json:"name" binding:"required"`
+	Components []BundleComponentRequest `json:"components" binding:"required,min=1,dive"`
+}
+
+type BundleComponentRequest struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,min=1"`
+}
+
+// StockMovement is a change of the stock of a product, see migrations/014_bundles_stock_movements.sql
+type StockMovement struct {
+	ID              int64                `json:"id"`
+	ProductID       string               `json:"product_id"`
+	BundleID        *int64               `json:"bundle_id,omitempty"`
+	Kind            string               `json:"kind"`
+	Quantity        int                  `json:"quantity" pg:",use_zero"`
+	Note            string               `json:"note"`
+	CreatedBy       string               `json:"created_by"`
+	CreatedAt       time.Time            `json:"created_at"`
+	SupplierID      string               `json:"supplier_id,omitempty"`
+	OrderedAt       *time.Time           `json:"ordered_at,omitempty"`
+	ExpectedAt      *time.Time           `json:"expected_at,omitempty"`
+	OrderedQuantity int                  `json:"ordered_quantity,omitempty"`
+	UnitCost        *utils.Decimal       `json:"unit_cost,omitempty" swaggertype:"number"`
+	Currency        string               `json:"currency,omitempty"`
+	ListUnitCost    *utils.Decimal       `json:"list_unit_cost,omitempty" swaggertype:"number"`
+	Allocations     []StockLotAllocation `json:"lots,omitempty" pg:"rel:has-many,join_fk:movement_id"`
+}
+
+// StockLot is a lot of a product in a location, see migrations/017_stock_lots.sql
+type StockLot struct {
+	ID               int64     `json:"id"`
+	ProductID        string    `json:"product_id"`
+	LotNumber        string    `json:"lot_number"`
+	Location         string    `json:"location" pg:",use_zero"`
+	ExpiryDate       *string   `json:"expiry_date" pg:"type:date"`
+	Quantity         int       `json:"quantity" pg:",use_zero"`
+	ReceivedQuantity int       `json:"received_quantity" pg:",use_zero"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Expired          bool      `json:"expired" pg:"-"`
+}
+
+// StockLotAllocation is the quantity of a lot moved by a stock movement
+type StockLotAllocation struct {
+	MovementID int64  `json:"-" pg:",pk"`
+	LotID      int64  `json:"lot_id" pg:",pk"`
+	LotNumber  string `json:"lot_number" pg:"-"`
+	Quantity   int    `json:"quantity"`
+}
+
+// StockLotRequest is the lot received by a movement, or the lot taken by a movement instead of FEFO
+type StockLotRequest struct {
+	LotNumber  string `json:"lot_number" binding:"required"`
+	ExpiryDate string `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"`
+}
+
+// ExpiringProduct is a product with the lots which expire in the period of GET /products/expiring
+type ExpiringProduct struct {
+	Product          *Product   `json:"product"`
+	ExpiringQuantity int        `json:"expiring_quantity"`
+	Lots             []StockLot `json:"lots"`
+}
+
+/*
+StockMovementRequest moves the stock of a product or sells a bundle:
+  - ProductID or BundleID, a bundle can only be sold
+  - Kind: receipt and sale take a positive Quantity, adjustment a signed Quantity
+  - Receipt: the purchase order delivered by a receipt of a product
+  - Lot: the lot received, or the lot taken instead of the lots expiring first (FEFO)
+  - Location: the location of the lots, the stock city of the product for a received lot by default
+*/
+type StockMovementRequest struct {
+	ProductID string               `json:"product_id"`
+	BundleID  int64                `json:"bundle_id"`
+	Kind      string               `json:"kind" binding:"required,oneof=receipt sale adjustment"`
+	Quantity  int                  `json:"quantity" binding:"required"`
+	Note      string               `json:"note"`
+	Receipt   *StockReceiptRequest `json:"receipt"`
+	Lot       *StockLotRequest     `json:"lot"`
+	Location  string               `json:"location"`
+}
+
+/*
+StockReceiptRequest is the purchase order delivered by a receipt, used by the supplier scorecard.
+Without them, the expected date is the order date plus the lead time of the supplier and the ordered quantity is the received quantity.
+Without unit cost, the receipt is left out of the price variance.
+*/
+type StockReceiptRequest struct {
+	SupplierID      string         `json:"supplier_id" binding:"required"`
+	OrderedAt       time.Time      `json:"ordered_at" binding:"required"`
+	ExpectedAt      *time.Time     `json:"expected_at"`
+	OrderedQuantity int            `json:"ordered_quantity" binding:"omitempty,min=1"`
+	UnitCost        *utils.Decimal `json:"unit_cost" swaggertype:"number"`
+	Currency        string         `json:"currency"`
 }
 
 type Category struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID              string              `json:"id"`
+	Name            string              `json:"name"`
+	ParentID        string              `json:"parent_id,omitempty"`
+	AttributeSchema []CategoryAttribute `json:"attribute_schema,omitempty" pg:"type:jsonb"`
+	Children        []*Category         `json:"children,omitempty" pg:"-"`
+}
+
+// CategoryMoveRequest moves a category and its subtree under ParentID, an empty ParentID makes it a root
+type CategoryMoveRequest struct {
+	ParentID string `json:"parent_id"`
+}
+
+/*
+CategoryAttribute is an attribute of the products of a category:
+  - Type: string, number, enum or date (YYYY-MM-DD)
+  - Values: the accepted values of an enum
+*/
+type CategoryAttribute struct {
+	Name     string   `json:"name" binding:"required"`
+	Type     string   `json:"type" binding:"required,oneof=string number enum date"`
+	Required bool     `json:"required"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type CategoryAttributesRequest struct {
+	Attributes []CategoryAttribute `json:"attributes" binding:"dive"`
 }
 
 type ProductRequest struct {
 	LastReference string `form:"last_reference"`
 	PerPage       int    `form:"perPage"`
+	Variants      bool   `form:"variants"`
 }
 
 type Supplier struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+}
+
+// ProductSupplier is the offer of a supplier for a product, see migrations/015_product_suppliers.sql
+type ProductSupplier struct {
+	ID               int64         `json:"id"`
+	ProductID        string        `json:"product_id"`
+	SupplierID       string        `json:"supplier_id"`
+	SKU              string        `json:"sku" pg:",use_zero"`
+	UnitCost         utils.Decimal `json:"unit_cost" swaggertype:"number" pg:",use_zero"`
+	Currency         string        `json:"currency"`
+	MinOrderQuantity int           `json:"min_order_quantity"`
+	LeadTimeDays     int           `json:"lead_time_days" pg:",use_zero"`
+	IsPreferred      bool          `json:"is_preferred" pg:",use_zero"`
+	CreatedAt        time.Time     `json:"created_at"`
+	UpdatedAt        time.Time     `json:"updated_at"`
+	Supplier         *Supplier     `json:"supplier,omitempty" pg:"rel:has-one"`
+}
+
+// ProductSupplierRequest adds or replaces the offer of a supplier, the currency of the supplier is used without currency
+type ProductSupplierRequest struct {
+	SupplierID       string        `json:"supplier_id"`
+	SKU              string        `json:"sku"`
+	UnitCost         utils.Decimal `json:"unit_cost" swaggertype:"number" binding:"min=0"`
+	Currency         string        `json:"currency"`
+	MinOrderQuantity int           `json:"min_order_quantity" binding:"omitempty,min=1"`
+	LeadTimeDays     int           `json:"lead_time_days" binding:"min=0"`
+}
+
+// SupplierOffer is the offer of a supplier for an order, TotalCost is in the currency of the recommendation
+type SupplierOffer struct {
+	ProductSupplier
+	TotalCost utils.Decimal `json:"total_cost" swaggertype:"number"`
 }
 
 type ProductsPerCategoryResponse struct {
@@ -138,7 +625,137 @@ type ProductsPerCategoryResponse struct {
 	TotalProducts int    `json:"total_products"`
 }
 
-type ProductsPerSupplierResponse struct {
-	SupplierName  string `json:"supplier_name"`
+// CategoryRollupResponse counts the products of a category, RollupTotal includes the products of its descendants
+type CategoryRollupResponse struct {
+	CategoryID    string `json:"category_id"`
+	CategoryName  string `json:"category_name"`
+	ParentID      string `json:"parent_id,omitempty"`
+	Depth         int    `json:"depth"`
 	TotalProducts int    `json:"total_products"`
+	RollupTotal   int    `json:"rollup_total"`
+}
+
+type ProductsPerSupplierResponse struct {
+	SupplierName  string                `json:"supplier_name"`
+	TotalProducts int                   `json:"total_products"`
+	Scorecard     *SupplierScorecardRow `json:"scorecard,omitempty"`
+}
+
+/*
+SupplierScorecardRow is the performance of a supplier on its receipts, in total or in an interval:
+  - OnTimeRate: share of the receipts delivered at the latest on their expected date
+  - AvgLeadTimeDays: average days between the order and the receipt
+  - FillRate: received quantity divided by ordered quantity
+  - PriceVariance: average of (unit cost - list unit cost) / list unit cost, positive when paid above the price list
+*/
+type SupplierScorecardRow struct {
+	Supplier         *string        `json:"-"`
+	Bucket           *time.Time     `json:"-"`
+	Receipts         int            `json:"receipts"`
+	ReceivedQuantity int64          `json:"received_quantity"`
+	OrderedQuantity  int64          `json:"ordered_quantity"`
+	OnTimeRate       *utils.Decimal `json:"on_time_rate" swaggertype:"number"`
+	AvgLeadTimeDays  *utils.Decimal `json:"avg_lead_time_days" swaggertype:"number"`
+	FillRate         *utils.Decimal `json:"fill_rate" swaggertype:"number"`
+	PriceVariance    *utils.Decimal `json:"price_variance" swaggertype:"number"`
+}
+
+type SupplierScorecardBucket struct {
+	Start string `json:"start"`
+	SupplierScorecardRow
+}
+
+type ProductVersion struct {
+	ID        int64     `json:"-"`
+	ProductID string    `json:"product_id"`
+	Version   int       `json:"version"`
+	Action    string    `json:"action"`
+	Snapshot  Product   `json:"snapshot" pg:"type:jsonb"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type ProductHistoryResponse struct {
+	ProductVersion
+	Changes map[string]models.FieldChange `json:"changes"`
+}
+
+type ProductRevertRequest struct {
+	Version int `json:"version" binding:"required"`
+}
+
+type ProductPrice struct {
+	ID            int64         `json:"id"`
+	ProductID     string        `json:"product_id"`
+	Price         utils.Decimal `json:"price" swaggertype:"number" pg:",use_zero"`
+	Currency      string        `json:"currency"`
+	EffectiveFrom time.Time     `json:"effective_from"`
+	AppliedAt     *time.Time    `json:"applied_at"`
+	CreatedBy     string        `json:"created_by"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+type ProductPriceScheduleRequest struct {
+	Price         *utils.Decimal `json:"price" swaggertype:"number"`
+	Currency      string         `json:"currency"`
+	EffectiveFrom time.Time      `json:"effective_from" binding:"required"`
+}
+
+type ExchangeRate struct {
+	ID        int64         `json:"id"`
+	Currency  string        `json:"currency"`
+	Rate      utils.Decimal `json:"rate" swaggertype:"number"`
+	RateDate  time.Time     `json:"rate_date"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type ExchangeRateRequest struct {
+	Currency string        `json:"currency" binding:"required"`
+	Rate     utils.Decimal `json:"rate" binding:"required" swaggertype:"number"`
+	RateDate string        `json:"rate_date" binding:"required"`
+}
+
+type StatisticsRow struct {
+	CategoryID    *string
+	Category      *string
+	Supplier      *string
+	StockCity     *string
+	Status        *string
+	Count         int
+	SumQuantity   int64
+	AvgQuantity   utils.Decimal
+	SumStockValue utils.Decimal
+	AvgPrice      utils.Decimal
+	MinPrice      utils.Decimal
+	MaxPrice      utils.Decimal
+	ComputedAt    time.Time
+}
+
+// ProductStatistics is a row of the pre-aggregated statistics of the products, see migrations/007_product_statistics.sql and 018
+type ProductStatistics struct {
+	tableName     struct{} `pg:"product_statistics,alias:product"`
+	CategoryID    *string
+	SupplierID    *string
+	StockCity     *string
+	Status        *string
+	Currency      string
+	Count         int
+	SumQuantity   int64
+	SumPrice      utils.Decimal
+	SumStockValue utils.Decimal
+	MinPrice      utils.Decimal
+	MaxPrice      utils.Decimal
+	ComputedAt    time.Time
+}
+
+type ProductsAddedRow struct {
+	Name          *string
+	Bucket        time.Time
+	TotalProducts int
+}
+
+type ProductsAddedBucket struct {
+	Start      string `json:"start"`
+	Count      int    `json:"count"`
+	Cumulative int    `json:"cumulative"`
 }
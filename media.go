@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"github.com/jung-kurt/gofpdf"
+	"io"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/middlewares"
+	"manage-products/models"
+	"manage-products/storage"
+	"manage-products/utils"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// thumbnailSize is the max width and height of the thumbnails of the images
+const thumbnailSize = 256
+
+// thumbnailExtensions are the extensions of the thumbnails by content type, mime.ExtensionsByType gives .jfif for a jpeg
+var thumbnailExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// mediaTypes are the accepted types of the uploaded files and their kind
+var mediaTypes = []struct {
+	ContentType string
+	Kind        string
+}{
+	{"image/jpeg", "image"},
+	{"image/png", "image"},
+	{"image/gif", "image"},
+	{"image/webp", "image"},
+	{"application/pdf", "document"},
+	{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "document"},
+	{"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "document"},
+	{"text/csv", "document"},
+	{"text/plain", "document"},
+}
+
+// mediaFormOverhead is the size allowed for the other fields and the boundaries of an upload, above the max size of the file
+const mediaFormOverhead = 64 << 10
+
+// maxMediaSize is the max size of an uploaded file: MEDIA_MAX_SIZE_MB or 10 MB by default
+func maxMediaSize() int64 {
+	if size, err := strconv.Atoi(os.Getenv("MEDIA_MAX_SIZE_MB")); err == nil && size > 0 {
+		return int64(size) << 20
+	}
+	return 10 << 20
+}
+
+func (m *ProductMedia) withURLs() *ProductMedia {
+	m.URL = fmt.Sprintf("/products/%v/media/%v", m.ProductID, m.ID)
+	if m.ThumbnailKey != "" {
+		m.ThumbnailURL = m.URL + "/thumbnail"
+	}
+	return m
+}
+
+// @Summary      Get images and documents of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The primary image is first, url downloads the file and thumbnail_url the thumbnail of an image
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media [get]
+func (h *ProductHandler) GetProductMedia(c *gin.Context) {
+	media := make([]ProductMedia, 0)
+	err := h.db.Model(&media).
+		Where("product_id = ?", c.Param("id")).
+		Order("is_primary DESC", "created_at ASC", "id ASC").
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get media"))
+		return
+	}
+
+	for i := range media {
+		media[i].withURLs()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"media": media,
+	})
+}
+
+// @Summary      Upload image or document of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Images: jpeg, png, gif, webp, a thumbnail is generated. Documents: pdf, docx, xlsx, csv, txt
+// @Description  The type is detected from the content of the file, reply 415 for another type and 413 above MEDIA_MAX_SIZE_MB
+// @Description  The first image of a product is its primary image
+// @Accept       multipart/form-data
+// @Param        id  path  int  true  "Product ID"
+// @Param        file  formData  file  true  "Image or document"
+// @Param        primary  formData  bool  false  "Make the image the primary image of the product"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media [post]
+func (h *ProductHandler) UploadProductMedia(c *gin.Context) {
+	product := &Product{ID: c.Param("id")}
+	if err := h.db.Model(product).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	// the body is limited before it is parsed, a larger file is not read until its end
+	maxSize := maxMediaSize()
+	tooLarge := apierrors.New(http.StatusRequestEntityTooLarge, apierrors.CodeInvalidRequest,
+		fmt.Sprintf("file is larger than %v MB", maxSize>>20))
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+mediaFormOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			apierrors.Reply(c, tooLarge)
+			return
+		}
+
+		apierrors.Reply(c, apierrors.Validation(apierrors.FieldError{Field: "file", Code: apierrors.FieldRequired, Message: "file is required"}))
+		return
+	}
+
+	if fileHeader.Size > maxSize {
+		apierrors.Reply(c, tooLarge)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		apierrors.Reply(c, apierrors.BadRequest("invalid request: "+err.Error()))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		apierrors.Reply(c, apierrors.BadRequest("invalid request: "+err.Error()))
+		return
+	}
+
+	// the type sent by the client is not trusted
+	detected := mimetype.Detect(data)
+	media := &ProductMedia{
+		ProductID:  product.ID,
+		FileName:   filepath.Base(fileHeader.Filename),
+		Size:       int64(len(data)),
+		IsPrimary:  c.PostForm("primary") == "true",
+		CreatedAt:  time.Now(),
+		UploadedBy: currentUserEmail(c),
+	}
+	for _, mediaType := range mediaTypes {
+		if detected.Is(mediaType.ContentType) {
+			media.ContentType, media.Kind = mediaType.ContentType, mediaType.Kind
+			break
+		}
+	}
+	if media.Kind == "" {
+		apierrors.Reply(c, apierrors.New(http.StatusUnsupportedMediaType, apierrors.CodeUnsupportedMediaType,
+			fmt.Sprintf("files of type %v are not accepted", detected.String())))
+		return
+	}
+	if media.Kind != "image" && media.IsPrimary {
+		apierrors.Reply(c, apierrors.InvalidField("primary", "only an image can be primary"))
+		return
+	}
+
+	var thumbnail []byte
+	var thumbnailType string
+	if media.Kind == "image" {
+		if thumbnail, thumbnailType, err = utils.Thumbnail(data, thumbnailSize); err != nil {
+			apierrors.Reply(c, apierrors.InvalidField("file", "image can not be read: "+err.Error()))
+			return
+		}
+	}
+
+	name := randomName()
+	media.StorageKey = fmt.Sprintf("products/%v/%v%v", product.ID, name, detected.Extension())
+	if err := h.storage.Put(c, media.StorageKey, data, media.ContentType); err != nil {
+		apierrors.Reply(c, apierrors.Internal(err, "have error when store file"))
+		return
+	}
+	if thumbnail != nil {
+		media.ThumbnailKey = fmt.Sprintf("products/%v/%v-thumbnail%v", product.ID, name, thumbnailExtensions[thumbnailType])
+		if err := h.storage.Put(c, media.ThumbnailKey, thumbnail, thumbnailType); err != nil {
+			h.deleteMediaFiles(media)
+			apierrors.Reply(c, apierrors.Internal(err, "have error when store thumbnail"))
+			return
+		}
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if media.Kind == "image" && !media.IsPrimary {
+			// the first image of a product is its primary image
+			hasPrimary, err := tx.Model((*ProductMedia)(nil)).Where("product_id = ?", product.ID).Where("is_primary").Exists()
+			if err != nil {
+				return err
+			}
+			media.IsPrimary = !hasPrimary
+		}
+		if media.IsPrimary {
+			if err := unsetPrimaryMedia(tx, product.ID); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.Model(media).Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "upload", Entity: "product_media", EntityID: fmt.Sprint(media.ID)}, nil, media)
+	})
+	if err != nil {
+		h.deleteMediaFiles(media)
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when save media"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":   "upload media successfully",
+		"media": media.withURLs(),
+	})
+}
+
+// @Summary      Download image or document of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Product ID"
+// @Param        media_id  path  int  true  "Media ID"
+// @Success      200  {file}  file
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media/:media_id [get]
+func (h *ProductHandler) DownloadProductMedia(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	h.replyMediaFile(c, media.StorageKey, media.ContentType, media.FileName)
+}
+
+// @Summary      Download thumbnail of image of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The image scaled to fit in 256x256, documents have no thumbnail
+// @Param        id  path  int  true  "Product ID"
+// @Param        media_id  path  int  true  "Media ID"
+// @Success      200  {file}  file
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media/:media_id/thumbnail [get]
+func (h *ProductHandler) DownloadProductMediaThumbnail(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	if media.ThumbnailKey == "" {
+		apierrors.Reply(c, apierrors.NotFound("media has no thumbnail"))
+		return
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(media.ThumbnailKey))
+	h.replyMediaFile(c, media.ThumbnailKey, contentType, "thumbnail-"+media.FileName)
+}
+
+// @Summary      Make image the primary image of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Product ID"
+// @Param        media_id  path  int  true  "Media ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media/:media_id/primary [post]
+func (h *ProductHandler) SetPrimaryProductMedia(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	if media.Kind != "image" {
+		apierrors.Reply(c, apierrors.InvalidField("media_id", "only an image can be primary"))
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if err := unsetPrimaryMedia(tx, media.ProductID); err != nil {
+			return err
+		}
+
+		media.IsPrimary = true
+		if _, err := tx.Model(media).Column("is_primary").WherePK().Update(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "set_primary", Entity: "product_media", EntityID: fmt.Sprint(media.ID)}, nil, media)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when set primary media"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":   "set primary media successfully",
+		"media": media.withURLs(),
+	})
+}
+
+// @Summary      Delete image or document of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  When the primary image is deleted, the oldest other image becomes primary
+// @Param        id  path  int  true  "Product ID"
+// @Param        media_id  path  int  true  "Media ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/media/:media_id [delete]
+func (h *ProductHandler) DeleteProductMedia(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(media).WherePK().Delete(); err != nil {
+			return err
+		}
+
+		if media.IsPrimary {
+			_, err := tx.Exec(`
+				UPDATE product_media SET is_primary = TRUE
+				WHERE id = (
+					SELECT id FROM product_media WHERE product_id = ? AND kind = 'image'
+					ORDER BY created_at ASC, id ASC LIMIT 1
+				)`, media.ProductID)
+			if err != nil {
+				return err
+			}
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "product_media", EntityID: fmt.Sprint(media.ID)}, media, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when delete media"))
+		return
+	}
+
+	// the files are deleted once the row is, a failed transaction keeps them
+	h.deleteMediaFiles(media)
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "delete media successfully",
+	})
+}
+
+// findMedia selects the media of the path, it replies 404 and returns false if it does not exist
+func (h *ProductHandler) findMedia(c *gin.Context) (*ProductMedia, bool) {
+	media := &ProductMedia{}
+	err := h.db.Model(media).
+		Where("id = ?", c.Param("media_id")).
+		Where("product_id = ?", c.Param("id")).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("media not found"))
+			return nil, false
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get media"))
+		return nil, false
+	}
+	return media, true
+}
+
+func (h *ProductHandler) replyMediaFile(c *gin.Context, key string, contentType string, fileName string) {
+	data, err := h.storage.Get(c, key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			apierrors.Reply(c, apierrors.NotFound("file not found in storage"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.Internal(err, "have error when read file"))
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": fileName}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// deleteMediaFiles removes the file and the thumbnail of a media from the storage, an error is only logged
+func (h *ProductHandler) deleteMediaFiles(media *ProductMedia) {
+	deleteMediaFiles(h.storage, media)
+}
+
+func deleteMediaFiles(store storage.Storage, media *ProductMedia) {
+	for _, key := range []string{media.StorageKey, media.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := store.Delete(context.Background(), key); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// purgeProductMedia deletes the media of the purged products, their files are deleted by the caller after the commit
+func purgeProductMedia(db orm.DB, ids []string) ([]ProductMedia, error) {
+	media := make([]ProductMedia, 0)
+	_, err := db.Query(&media, `DELETE FROM product_media WHERE product_id IN (?) RETURNING *`, pg.In(ids))
+	return media, err
+}
+
+func unsetPrimaryMedia(db orm.DB, productID string) error {
+	_, err := db.Model((*ProductMedia)(nil)).
+		Set("is_primary = FALSE").
+		Where("product_id = ?", productID).
+		Where("is_primary").
+		Update()
+	return err
+}
+
+func randomName() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func currentUserEmail(c *gin.Context) string {
+	email, _ := middlewares.CurrentUser(c)
+	return email
+}
+
+// registerPrimaryThumbnails registers the thumbnails of the primary images of the products in the pdf,
+// it returns the image names by product id, a missing file is skipped
+func (h *ProductHandler) registerPrimaryThumbnails(c *gin.Context, pdf *gofpdf.Fpdf, products []Product) (map[string]string, *apierrors.Error) {
+	images := make(map[string]string)
+	if len(products) == 0 {
+		return images, nil
+	}
+
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	media := make([]ProductMedia, 0)
+	err := h.db.Model(&media).
+		Where("product_id IN (?)", pg.In(ids)).
+		Where("is_primary").
+		Where("thumbnail_key IS NOT NULL").
+		Select()
+	if err != nil {
+		return nil, apierrors.FromDB(err, "have error when get media")
+	}
+
+	for _, m := range media {
+		imageType := "PNG"
+		if ext := filepath.Ext(m.ThumbnailKey); ext == ".jpg" || ext == ".jpeg" {
+			imageType = "JPG"
+		}
+
+		data, err := h.storage.Get(c, m.ThumbnailKey)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		name := m.ThumbnailKey
+		pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: imageType}, bytes.NewReader(data))
+		if pdf.Ok() {
+			images[m.ProductID] = name
+		} else {
+			fmt.Println(pdf.Error())
+			pdf.ClearError()
+		}
+	}
+	return images, nil
+}
+
+// drawCellImage draws a registered image centered in the next cell of the row, scaled to fit in 8mm
+func drawCellImage(pdf *gofpdf.Fpdf, name string, cellWidth float64, cellHeight float64) {
+	info := pdf.GetImageInfo(name)
+	if info == nil {
+		return
+	}
+
+	width, height := 8.0, 8.0
+	if info.Width() > info.Height() {
+		height = 8 * info.Height() / info.Width()
+	} else {
+		width = 8 * info.Width() / info.Height()
+	}
+
+	x, y := pdf.GetXY()
+	pdf.ImageOptions(name, x+(cellWidth-width)/2, y+(cellHeight-height)/2, width, height, false, gofpdf.ImageOptions{}, 0, "")
+	pdf.SetXY(x, y)
+}
@@ -3,40 +3,35 @@ package middlewares
 import (
 	"fmt"
 	"github.com/gin-gonic/gin"
+	"manage-products/apierrors"
 	"manage-products/utils"
-	"net/http"
 	"strings"
 )
 
+// ClaimsKey is the key of the jwt.MapClaims of the authenticated user in gin.Context
+const ClaimsKey = "claims"
+
 func AuthenticateMiddleware(c *gin.Context) {
 	tokenHeader := c.GetHeader("Authorization")
 	if tokenHeader == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "missing token",
-		})
-		c.Abort()
+		apierrors.Reply(c, apierrors.Unauthenticated("missing token"))
 		return
 	}
 
 	splitToken := strings.Split(tokenHeader, " ")
 	if len(splitToken) != 2 || !strings.EqualFold(splitToken[0], "Bearer") {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "invalid bearer token",
-		})
-		c.Abort()
+		apierrors.Reply(c, apierrors.Unauthenticated("invalid bearer token"))
 		return
 	}
 
 	token, err := utils.VerifyToken(splitToken[1])
 	if err != nil {
 		fmt.Printf("Token verification failed: %v\\n", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "invalid token",
-		})
-		c.Abort()
+		apierrors.Reply(c, apierrors.Unauthenticated("invalid token"))
 		return
 	}
 
 	fmt.Printf("Token verified successfully. Claims: %+v\\n", token.Claims)
+	c.Set(ClaimsKey, token.Claims)
 	c.Next()
 }
@@ -0,0 +1,32 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"manage-products/apierrors"
+)
+
+// RequireRole must be used after AuthenticateMiddleware, it rejects users whose role is not in roles
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, role := CurrentUser(c)
+
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		apierrors.Reply(c, apierrors.PermissionDenied("permission denied"))
+	}
+}
+
+// CurrentUser returns the email and role of the authenticated user, empty if the route is not authenticated
+func CurrentUser(c *gin.Context) (string, string) {
+	claims, _ := c.Get(ClaimsKey)
+	mapClaims, _ := claims.(jwt.MapClaims)
+	email, _ := mapClaims["email"].(string)
+	role, _ := mapClaims["role"].(string)
+	return email, role
+}
@@ -0,0 +1,159 @@
+package middlewares
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"manage-products/cache"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+CacheResponse caches the successful responses of a GET route for ttl, tags are the data the response depends on.
+  - The responses have an ETag and a Last-Modified header, If-None-Match and If-Modified-Since are answered with 304
+  - X-Cache is HIT or MISS
+  - An error of the cache is only logged, the request is then handled without cache
+  - A response is not stored if one of its tags was invalidated while it was computed
+*/
+func CacheResponse(store cache.Cache, ttl time.Duration, tags ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.Request.Method + " " + c.Request.URL.RequestURI()
+
+		entry, err := store.Get(c, key)
+		if err != nil {
+			fmt.Println(err)
+		}
+		if entry != nil {
+			c.Header("X-Cache", "HIT")
+			replyCacheEntry(c, entry)
+			c.Abort()
+			return
+		}
+
+		// read before the handler, a write which invalidates the tags in between prevents the store
+		generations, err := store.Generations(c, tags...)
+		if err != nil {
+			fmt.Println(err)
+		}
+
+		// the headers set before, e.g. X-Request-ID, are not cached
+		headersBefore := c.Writer.Header().Clone()
+		writer := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.status != http.StatusOK {
+			c.Writer.WriteHeader(writer.status)
+			c.Writer.Write(writer.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(writer.body.Bytes())
+		entry = &cache.Entry{
+			Status:       writer.status,
+			Header:       http.Header{},
+			Body:         writer.body.Bytes(),
+			ETag:         `"` + hex.EncodeToString(sum[:16]) + `"`,
+			LastModified: time.Now().UTC().Truncate(time.Second),
+		}
+		for name, values := range c.Writer.Header() {
+			if _, ok := headersBefore[name]; !ok {
+				entry.Header[name] = values
+			}
+		}
+
+		if generations != nil {
+			if err = store.Set(c, key, entry, ttl, generations); err != nil {
+				fmt.Println(err)
+			}
+		}
+
+		c.Header("X-Cache", "MISS")
+		replyCacheEntry(c, entry)
+	}
+}
+
+// InvalidateCache invalidates the cached responses of tags after a successful write
+func InvalidateCache(store cache.Cache, tags ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if c.Writer.Status() < http.StatusBadRequest {
+			if err := store.InvalidateTags(c, tags...); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func replyCacheEntry(c *gin.Context, entry *cache.Entry) {
+	for name, values := range entry.Header {
+		c.Writer.Header()[name] = values
+	}
+	c.Header("ETag", entry.ETag)
+	c.Header("Last-Modified", entry.LastModified.Format(http.TimeFormat))
+
+	if notModified(c, entry) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	c.Writer.WriteHeader(entry.Status)
+	c.Writer.Write(entry.Body)
+}
+
+// notModified evaluates the conditional headers of a GET, If-Modified-Since is ignored when If-None-Match is sent
+func notModified(c *gin.Context, entry *cache.Entry) bool {
+	if ifNoneMatch := c.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		for _, etag := range strings.Split(ifNoneMatch, ",") {
+			etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+			if etag == entry.ETag || etag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+
+	if since, err := http.ParseTime(c.GetHeader("If-Modified-Since")); err == nil {
+		return !entry.LastModified.After(since)
+	}
+	return false
+}
+
+// bufferedWriter keeps the response of the handler in memory, so the cache headers can be set before it is written
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return false
+}
@@ -0,0 +1,24 @@
+package middlewares
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDKey is the key of the request id in gin.Context
+const RequestIDKey = "request_id"
+
+// RequestID reuses the X-Request-ID header of the client or generates a new one
+func RequestID(c *gin.Context) {
+	requestID := c.GetHeader("X-Request-ID")
+	if requestID == "" {
+		b := make([]byte, 16)
+		rand.Read(b)
+		requestID = hex.EncodeToString(b)
+	}
+
+	c.Set(RequestIDKey, requestID)
+	c.Header("X-Request-ID", requestID)
+	c.Next()
+}
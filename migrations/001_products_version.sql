@@ -0,0 +1,6 @@
+-- Optimistic concurrency control for products:
+--   - version: incremented on every write, exposed to clients as the ETag
+--   - updated_at: time of the last write
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS version    INTEGER     NOT NULL DEFAULT 1,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
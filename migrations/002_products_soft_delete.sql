@@ -0,0 +1,6 @@
+-- Soft delete for products: a deleted product stays in the table with deleted_at set
+-- until it is purged by an admin or by the retention job
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS products_deleted_at_idx ON products (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,17 @@
+-- Audit log of every write operation
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         BIGSERIAL PRIMARY KEY,
+    actor      TEXT        NOT NULL DEFAULT '',
+    actor_role TEXT        NOT NULL DEFAULT '',
+    action     TEXT        NOT NULL,
+    entity     TEXT        NOT NULL,
+    entity_id  TEXT        NOT NULL DEFAULT '',
+    changes    JSONB,
+    client_ip  TEXT        NOT NULL DEFAULT '',
+    request_id TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor, created_at);
+CREATE INDEX IF NOT EXISTS audit_events_entity_idx ON audit_events (entity, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_events_created_at_idx ON audit_events (created_at);
@@ -0,0 +1,33 @@
+-- Every version of every product, written on each product write
+CREATE TABLE IF NOT EXISTS product_versions (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id TEXT        NOT NULL,
+    version    INTEGER     NOT NULL,
+    action     TEXT        NOT NULL,
+    snapshot   JSONB       NOT NULL,
+    changed_by TEXT        NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (product_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS product_versions_changed_at_idx ON product_versions (product_id, changed_at);
+
+-- The current state of existing products is their first known version,
+-- the snapshot has the same shape as the json of a product in the api
+INSERT INTO product_versions (product_id, version, action, snapshot, changed_at)
+SELECT p.id::TEXT, p.version, 'create', jsonb_build_object(
+           'id', p.id::TEXT,
+           'name', p.name,
+           'reference', p.reference,
+           'added_date', to_char(p.added_date::TIMESTAMPTZ AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"'),
+           'status', p.status,
+           'category_id', p.category_id::TEXT,
+           'price', p.price,
+           'stock_city', p.stock_city,
+           'supplier_id', p.supplier_id::TEXT,
+           'quantity', p.quantity,
+           'version', p.version,
+           'updated_at', to_char(p.updated_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+       ), p.updated_at
+FROM products p
+ON CONFLICT (product_id, version) DO NOTHING;
@@ -0,0 +1,20 @@
+-- Price timeline of products: every price change with the date it takes effect.
+-- A row with applied_at NULL is a scheduled change, applied by the price scheduler once effective_from is reached
+CREATE TABLE IF NOT EXISTS product_prices (
+    id             BIGSERIAL PRIMARY KEY,
+    product_id     TEXT        NOT NULL,
+    price          NUMERIC     NOT NULL,
+    effective_from TIMESTAMPTZ NOT NULL,
+    applied_at     TIMESTAMPTZ,
+    created_by     TEXT        NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_prices_product_idx ON product_prices (product_id, effective_from);
+CREATE INDEX IF NOT EXISTS product_prices_pending_idx ON product_prices (effective_from) WHERE applied_at IS NULL;
+
+-- The current price of existing products is effective since they were added
+INSERT INTO product_prices (product_id, price, effective_from, applied_at)
+SELECT p.id::TEXT, p.price, p.added_date, p.added_date
+FROM products p
+WHERE NOT EXISTS (SELECT 1 FROM product_prices pp WHERE pp.product_id = p.id::TEXT);
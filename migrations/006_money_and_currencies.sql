@@ -0,0 +1,26 @@
+-- Prices are exact decimals instead of floats, rounded to the cents they were meant to be
+ALTER TABLE products
+    ALTER COLUMN price TYPE NUMERIC(20, 4) USING ROUND(price::NUMERIC, 2);
+
+ALTER TABLE product_prices
+    ALTER COLUMN price TYPE NUMERIC(20, 4) USING ROUND(price::NUMERIC, 2);
+
+-- ISO 4217 currency of the prices, a supplier currency is the default for its new products
+ALTER TABLE suppliers
+    ADD COLUMN IF NOT EXISTS currency CHAR(3);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'EUR';
+
+ALTER TABLE product_prices
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'EUR';
+
+-- Dated exchange rates: rate is the value of 1 unit of currency in the base currency (BASE_CURRENCY)
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id         BIGSERIAL PRIMARY KEY,
+    currency   CHAR(3)        NOT NULL,
+    rate       NUMERIC(24, 8) NOT NULL CHECK (rate > 0),
+    rate_date  DATE           NOT NULL,
+    created_at TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    UNIQUE (currency, rate_date)
+);
@@ -0,0 +1,97 @@
+-- Pre-aggregated statistics of the products which are not deleted, one row per group of
+-- category, supplier, stock city, status and currency. The statistics endpoints group these rows
+-- instead of all the products.
+CREATE TABLE IF NOT EXISTS product_statistics AS
+SELECT p.category_id,
+       p.supplier_id,
+       p.stock_city,
+       p.status,
+       p.currency,
+       COUNT(*)                     AS count,
+       SUM(p.quantity)::BIGINT      AS sum_quantity,
+       SUM(p.price)                 AS sum_price,
+       SUM(p.price * p.quantity)    AS sum_stock_value,
+       MIN(p.price)                 AS min_price,
+       MAX(p.price)                 AS max_price,
+       NOW()                        AS computed_at
+FROM products p
+WHERE p.deleted_at IS NULL
+GROUP BY p.category_id, p.supplier_id, p.stock_city, p.status, p.currency;
+
+CREATE INDEX IF NOT EXISTS product_statistics_group_idx
+    ON product_statistics (category_id, supplier_id, stock_city, status, currency);
+
+-- Incremental refresh: only the group of a written product is computed again.
+-- The advisory lock serializes the refreshes of a group, so the last one sees the writes of the others.
+CREATE OR REPLACE FUNCTION refresh_product_statistics_group(g products) RETURNS VOID AS $$
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext(concat_ws('|', 'product_statistics',
+        g.category_id, g.supplier_id, g.stock_city, g.status, g.currency)));
+
+    DELETE FROM product_statistics s
+    WHERE s.category_id IS NOT DISTINCT FROM g.category_id
+      AND s.supplier_id IS NOT DISTINCT FROM g.supplier_id
+      AND s.stock_city IS NOT DISTINCT FROM g.stock_city
+      AND s.status IS NOT DISTINCT FROM g.status
+      AND s.currency IS NOT DISTINCT FROM g.currency;
+
+    INSERT INTO product_statistics
+    SELECT p.category_id, p.supplier_id, p.stock_city, p.status, p.currency,
+           COUNT(*), SUM(p.quantity)::BIGINT, SUM(p.price), SUM(p.price * p.quantity),
+           MIN(p.price), MAX(p.price), NOW()
+    FROM products p
+    WHERE p.deleted_at IS NULL
+      AND p.category_id IS NOT DISTINCT FROM g.category_id
+      AND p.supplier_id IS NOT DISTINCT FROM g.supplier_id
+      AND p.stock_city IS NOT DISTINCT FROM g.stock_city
+      AND p.status IS NOT DISTINCT FROM g.status
+      AND p.currency IS NOT DISTINCT FROM g.currency
+    GROUP BY p.category_id, p.supplier_id, p.stock_city, p.status, p.currency;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION products_refresh_statistics() RETURNS TRIGGER AS $$
+BEGIN
+    -- e.g. a new name does not change the statistics
+    IF TG_OP = 'UPDATE' AND
+        (OLD.category_id, OLD.supplier_id, OLD.stock_city, OLD.status, OLD.currency, OLD.price, OLD.quantity, OLD.deleted_at)
+            IS NOT DISTINCT FROM
+        (NEW.category_id, NEW.supplier_id, NEW.stock_city, NEW.status, NEW.currency, NEW.price, NEW.quantity, NEW.deleted_at) THEN
+        RETURN NULL;
+    END IF;
+
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM refresh_product_statistics_group(OLD);
+    END IF;
+
+    IF TG_OP IN ('INSERT', 'UPDATE') AND (TG_OP = 'INSERT' OR
+        (OLD.category_id, OLD.supplier_id, OLD.stock_city, OLD.status, OLD.currency)
+            IS DISTINCT FROM (NEW.category_id, NEW.supplier_id, NEW.stock_city, NEW.status, NEW.currency)) THEN
+        PERFORM refresh_product_statistics_group(NEW);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS products_refresh_statistics ON products;
+CREATE TRIGGER products_refresh_statistics
+    AFTER INSERT OR UPDATE OR DELETE ON products
+    FOR EACH ROW EXECUTE FUNCTION products_refresh_statistics();
+
+-- Full refresh, run on a schedule by the api in case a write bypassed the trigger (e.g. TRUNCATE)
+CREATE OR REPLACE FUNCTION refresh_product_statistics() RETURNS VOID AS $$
+BEGIN
+    LOCK TABLE product_statistics IN EXCLUSIVE MODE;
+
+    DELETE FROM product_statistics;
+
+    INSERT INTO product_statistics
+    SELECT p.category_id, p.supplier_id, p.stock_city, p.status, p.currency,
+           COUNT(*), SUM(p.quantity)::BIGINT, SUM(p.price), SUM(p.price * p.quantity),
+           MIN(p.price), MAX(p.price), NOW()
+    FROM products p
+    WHERE p.deleted_at IS NULL
+    GROUP BY p.category_id, p.supplier_id, p.stock_city, p.status, p.currency;
+END;
+$$ LANGUAGE plpgsql;
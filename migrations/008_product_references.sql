@@ -0,0 +1,21 @@
+-- References of products are unique, the products in the trash keep theirs so they can be restored.
+-- Duplicated references must be renamed before this migration.
+CREATE UNIQUE INDEX IF NOT EXISTS products_reference_key ON products (reference);
+
+-- Last number used by the reference generator per prefix and period, e.g. PROD and 202401 for PROD-202401-029
+CREATE TABLE IF NOT EXISTS product_reference_sequences (
+    prefix      TEXT    NOT NULL,
+    period      TEXT    NOT NULL,
+    last_number INTEGER NOT NULL,
+    PRIMARY KEY (prefix, period)
+);
+
+-- The generator continues after the existing references
+INSERT INTO product_reference_sequences (prefix, period, last_number)
+SELECT m.parts[1], m.parts[2], MAX(m.parts[3]::INTEGER)
+FROM products p
+CROSS JOIN LATERAL regexp_match(p.reference, '^([A-Z][A-Z0-9]*)-([0-9]{4,8})-([0-9]{1,9})$') AS m(parts)
+WHERE m.parts IS NOT NULL
+GROUP BY m.parts[1], m.parts[2]
+ON CONFLICT (prefix, period) DO UPDATE
+    SET last_number = GREATEST(product_reference_sequences.last_number, EXCLUDED.last_number);
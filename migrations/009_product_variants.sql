@@ -0,0 +1,17 @@
+-- Variants of a product which differ only by their options, e.g. {"size": "M", "colour": "red"}.
+--   - reference: own reference of the variant, PROD-202401-029-01 by default
+--   - price: override of the price of the product, NULL for the price of the product
+--   - quantity: stock of the variant
+CREATE TABLE IF NOT EXISTS product_variants (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id TEXT           NOT NULL,
+    reference  TEXT           NOT NULL,
+    options    JSONB          NOT NULL,
+    price      NUMERIC(20, 4),
+    quantity   INTEGER        NOT NULL DEFAULT 0,
+    version    INTEGER        NOT NULL DEFAULT 1,
+    created_at TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+    CONSTRAINT product_variants_reference_key UNIQUE (reference),
+    CONSTRAINT product_variants_options_key UNIQUE (product_id, options)
+);
@@ -0,0 +1,8 @@
+-- Typed attributes of the products of a category, e.g. [{"name": "voltage", "type": "number", "required": true}].
+-- A type is string, number, enum (one of values) or date (YYYY-MM-DD)
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS attribute_schema JSONB NOT NULL DEFAULT '[]';
+
+-- Values of the attributes of the category of a product, e.g. {"voltage": 220}
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1,11 @@
+-- Categories form a tree: parent_id is NULL for a root category.
+-- The column has the type of categories.id, the api rejects a move which would create a cycle
+DO $$
+BEGIN
+    EXECUTE format('ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id %s REFERENCES categories (id)',
+        (SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+         WHERE attrelid = 'categories'::regclass AND attname = 'id'));
+END
+$$;
+
+CREATE INDEX IF NOT EXISTS categories_parent_id_idx ON categories (parent_id);
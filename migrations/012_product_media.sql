@@ -0,0 +1,19 @@
+-- Images and documents (e.g. spec sheets) of the products, the files are in the storage of the api (STORAGE_DRIVER).
+--   - storage_key: key of the file, thumbnail_key: key of the thumbnail of an image
+--   - is_primary: the image shown for the product, e.g. in the pdf export, at most one per product
+CREATE TABLE IF NOT EXISTS product_media (
+    id            BIGSERIAL PRIMARY KEY,
+    product_id    TEXT        NOT NULL,
+    kind          TEXT        NOT NULL CHECK (kind IN ('image', 'document')),
+    file_name     TEXT        NOT NULL,
+    content_type  TEXT        NOT NULL,
+    size          BIGINT      NOT NULL,
+    storage_key   TEXT        NOT NULL,
+    thumbnail_key TEXT,
+    is_primary    BOOLEAN     NOT NULL DEFAULT FALSE,
+    uploaded_by   TEXT        NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS product_media_product_idx ON product_media (product_id, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS product_media_primary_key ON product_media (product_id) WHERE is_primary;
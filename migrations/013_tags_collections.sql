@@ -0,0 +1,36 @@
+-- Free-form tags and curated collections (e.g. "summer sale") of products, a product can have many of both.
+--   - tags.name: lower case, unique
+--   - product_collections.position: order of the products in the collection, new products are appended
+CREATE TABLE IF NOT EXISTS tags (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT tags_name_key UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS product_tags (
+    tag_id     BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    product_id TEXT   NOT NULL,
+    PRIMARY KEY (tag_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS product_tags_product_idx ON product_tags (product_id);
+
+CREATE TABLE IF NOT EXISTS collections (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT        NOT NULL,
+    description TEXT        NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT collections_name_key UNIQUE (name)
+);
+
+CREATE TABLE IF NOT EXISTS product_collections (
+    collection_id BIGINT      NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    product_id    TEXT        NOT NULL,
+    position      INTEGER     NOT NULL,
+    added_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (collection_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS product_collections_product_idx ON product_collections (product_id);
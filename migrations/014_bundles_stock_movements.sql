@@ -0,0 +1,36 @@
+-- Bundles (kits) sold as one item and made of component products, a bundle has no stock of its own:
+-- its availability is the minimum of the stock of each component divided by its quantity in the bundle.
+CREATE TABLE IF NOT EXISTS bundles (
+    id         BIGSERIAL PRIMARY KEY,
+    reference  TEXT        NOT NULL,
+    name       TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT bundles_reference_key UNIQUE (reference)
+);
+
+CREATE TABLE IF NOT EXISTS bundle_components (
+    bundle_id  BIGINT  NOT NULL REFERENCES bundles (id) ON DELETE CASCADE,
+    product_id TEXT    NOT NULL,
+    quantity   INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (bundle_id, product_id)
+);
+
+CREATE INDEX IF NOT EXISTS bundle_components_product_idx ON bundle_components (product_id);
+
+-- Every change of the stock (quantity) of a product.
+--   - kind: receipt, sale or adjustment
+--   - quantity: signed change of the stock, negative for a sale
+--   - bundle_id: the bundle sold when the product is a component of it
+CREATE TABLE IF NOT EXISTS stock_movements (
+    id         BIGSERIAL PRIMARY KEY,
+    product_id TEXT        NOT NULL,
+    bundle_id  BIGINT      REFERENCES bundles (id) ON DELETE SET NULL,
+    kind       TEXT        NOT NULL CHECK (kind IN ('receipt', 'sale', 'adjustment')),
+    quantity   INTEGER     NOT NULL,
+    note       TEXT        NOT NULL DEFAULT '',
+    created_by TEXT        NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS stock_movements_product_idx ON stock_movements (product_id, created_at);
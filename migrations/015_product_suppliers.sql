@@ -0,0 +1,30 @@
+-- Price list of the suppliers of a product, a product can be bought from several suppliers.
+--   - sku: reference of the product in the catalog of the supplier
+--   - unit_cost: price of one unit in currency, min_order_quantity: smallest quantity of an order
+--   - lead_time_days: days between the order and the delivery
+--   - is_preferred: the supplier to buy from by default, at most one per product
+-- products.supplier_id stays the supplier shown with the product.
+-- supplier_id has the type of suppliers.id
+DO $$
+BEGIN
+    EXECUTE format('
+        CREATE TABLE IF NOT EXISTS product_suppliers (
+            id                 BIGSERIAL PRIMARY KEY,
+            product_id         TEXT           NOT NULL,
+            supplier_id        %s             NOT NULL REFERENCES suppliers (id),
+            sku                TEXT           NOT NULL DEFAULT '''',
+            unit_cost          NUMERIC(20, 4) NOT NULL CHECK (unit_cost >= 0),
+            currency           TEXT           NOT NULL,
+            min_order_quantity INTEGER        NOT NULL DEFAULT 1 CHECK (min_order_quantity > 0),
+            lead_time_days     INTEGER        NOT NULL DEFAULT 0 CHECK (lead_time_days >= 0),
+            is_preferred       BOOLEAN        NOT NULL DEFAULT FALSE,
+            created_at         TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+            updated_at         TIMESTAMPTZ    NOT NULL DEFAULT NOW(),
+            CONSTRAINT product_suppliers_supplier_key UNIQUE (product_id, supplier_id)
+        )',
+        (SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+         WHERE attrelid = 'suppliers'::regclass AND attname = 'id'));
+END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS product_suppliers_preferred_key ON product_suppliers (product_id) WHERE is_preferred;
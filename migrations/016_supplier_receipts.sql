@@ -0,0 +1,22 @@
+-- A receipt (stock movement of kind receipt) can record the purchase order it delivers, for the supplier scorecard:
+--   - ordered_at, expected_at: date of the order and promised date of delivery, the delivery date is created_at
+--   - ordered_quantity: quantity of the order, the received quantity is quantity
+--   - unit_cost, currency: price paid, list_unit_cost: cost of the price list of the supplier (in currency) at the receipt
+-- supplier_id has the type of suppliers.id
+DO $$
+BEGIN
+    EXECUTE format('ALTER TABLE stock_movements ADD COLUMN IF NOT EXISTS supplier_id %s REFERENCES suppliers (id)',
+        (SELECT format_type(atttypid, atttypmod) FROM pg_attribute
+         WHERE attrelid = 'suppliers'::regclass AND attname = 'id'));
+END
+$$;
+
+ALTER TABLE stock_movements
+    ADD COLUMN IF NOT EXISTS ordered_at       TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS expected_at      TIMESTAMPTZ,
+    ADD COLUMN IF NOT EXISTS ordered_quantity INTEGER CHECK (ordered_quantity > 0),
+    ADD COLUMN IF NOT EXISTS unit_cost        NUMERIC(20, 4),
+    ADD COLUMN IF NOT EXISTS currency         TEXT,
+    ADD COLUMN IF NOT EXISTS list_unit_cost   NUMERIC(20, 4);
+
+CREATE INDEX IF NOT EXISTS stock_movements_supplier_idx ON stock_movements (supplier_id, created_at) WHERE supplier_id IS NOT NULL;
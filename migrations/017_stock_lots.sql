@@ -0,0 +1,30 @@
+-- Lots (batches) of the stock of a product, per location, e.g. for perishable goods.
+-- products.quantity stays the total stock: the stock received without lot is not in a lot.
+--   - location: where the lot is stored, the stock city of the product by default
+--   - expiry_date: NULL when the lot does not expire, the lots expiring first are taken first (FEFO)
+--   - quantity: remaining quantity, received_quantity: total received in the lot
+CREATE TABLE IF NOT EXISTS stock_lots (
+    id                BIGSERIAL PRIMARY KEY,
+    product_id        TEXT        NOT NULL,
+    lot_number        TEXT        NOT NULL,
+    location          TEXT        NOT NULL DEFAULT '',
+    expiry_date       DATE,
+    quantity          INTEGER     NOT NULL CHECK (quantity >= 0),
+    received_quantity INTEGER     NOT NULL DEFAULT 0,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT stock_lots_lot_key UNIQUE (product_id, lot_number, location)
+);
+
+CREATE INDEX IF NOT EXISTS stock_lots_lot_number_idx ON stock_lots (lot_number);
+CREATE INDEX IF NOT EXISTS stock_lots_expiry_idx ON stock_lots (expiry_date) WHERE quantity > 0;
+
+-- Quantity of each lot moved by a stock movement, signed like the movement, e.g. a sale taken from 2 lots.
+CREATE TABLE IF NOT EXISTS stock_lot_allocations (
+    movement_id BIGINT  NOT NULL REFERENCES stock_movements (id) ON DELETE CASCADE,
+    lot_id      BIGINT  NOT NULL REFERENCES stock_lots (id),
+    quantity    INTEGER NOT NULL,
+    PRIMARY KEY (movement_id, lot_id)
+);
+
+CREATE INDEX IF NOT EXISTS stock_lot_allocations_lot_idx ON stock_lot_allocations (lot_id);
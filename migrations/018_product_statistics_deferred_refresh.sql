@@ -0,0 +1,116 @@
+-- The row trigger of 007 locked the group of each written product row by row: two transactions
+-- writing products of the same groups in a different order could deadlock, e.g. two bulk updates
+-- or two stock movements. The groups written by a transaction are now queued by statement triggers
+-- and refreshed once at commit, their locks are taken in the order of their keys.
+
+DROP TRIGGER IF EXISTS products_refresh_statistics ON products;
+
+-- Groups to refresh at the commit of the transaction txid
+CREATE TABLE IF NOT EXISTS product_statistics_pending AS
+SELECT 0::BIGINT AS txid, p.category_id, p.supplier_id, p.stock_city, p.status, p.currency
+FROM products p
+WITH NO DATA;
+
+CREATE INDEX IF NOT EXISTS product_statistics_pending_txid_idx ON product_statistics_pending (txid);
+
+-- Queues the groups of the written rows, e.g. a new name does not change the statistics
+CREATE OR REPLACE FUNCTION products_queue_statistics() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO product_statistics_pending
+        SELECT DISTINCT txid_current(), n.category_id, n.supplier_id, n.stock_city, n.status, n.currency
+        FROM new_rows n;
+    ELSIF TG_OP = 'DELETE' THEN
+        INSERT INTO product_statistics_pending
+        SELECT DISTINCT txid_current(), o.category_id, o.supplier_id, o.stock_city, o.status, o.currency
+        FROM old_rows o;
+    ELSE
+        INSERT INTO product_statistics_pending
+        SELECT DISTINCT txid_current(), g.category_id, g.supplier_id, g.stock_city, g.status, g.currency
+        FROM old_rows o
+        JOIN new_rows n ON n.id = o.id
+        CROSS JOIN LATERAL (
+            VALUES (o.category_id, o.supplier_id, o.stock_city, o.status, o.currency),
+                   (n.category_id, n.supplier_id, n.stock_city, n.status, n.currency)
+        ) AS g (category_id, supplier_id, stock_city, status, currency)
+        WHERE (o.category_id, o.supplier_id, o.stock_city, o.status, o.currency, o.price, o.quantity, o.deleted_at)
+                  IS DISTINCT FROM
+              (n.category_id, n.supplier_id, n.stock_city, n.status, n.currency, n.price, n.quantity, n.deleted_at);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Refreshes the groups queued by the transaction and empties its queue, the first call at commit
+-- does the work and the following ones find an empty queue. The advisory locks serialize the
+-- refreshes of a group, so the last one sees the writes of the others.
+CREATE OR REPLACE FUNCTION products_refresh_statistics() RETURNS TRIGGER AS $$
+DECLARE
+    g RECORD;
+BEGIN
+    FOR g IN
+        SELECT DISTINCT q.category_id, q.supplier_id, q.stock_city, q.status, q.currency,
+               hashtext(concat_ws('|', 'product_statistics',
+                   q.category_id, q.supplier_id, q.stock_city, q.status, q.currency)) AS key
+        FROM product_statistics_pending q
+        WHERE q.txid = txid_current()
+        ORDER BY key
+    LOOP
+        PERFORM pg_advisory_xact_lock(g.key);
+
+        DELETE FROM product_statistics s
+        WHERE s.category_id IS NOT DISTINCT FROM g.category_id
+          AND s.supplier_id IS NOT DISTINCT FROM g.supplier_id
+          AND s.stock_city IS NOT DISTINCT FROM g.stock_city
+          AND s.status IS NOT DISTINCT FROM g.status
+          AND s.currency IS NOT DISTINCT FROM g.currency;
+
+        INSERT INTO product_statistics
+        SELECT p.category_id, p.supplier_id, p.stock_city, p.status, p.currency,
+               COUNT(*), SUM(p.quantity)::BIGINT, SUM(p.price), SUM(p.price * p.quantity),
+               MIN(p.price), MAX(p.price), NOW()
+        FROM products p
+        WHERE p.deleted_at IS NULL
+          AND p.category_id IS NOT DISTINCT FROM g.category_id
+          AND p.supplier_id IS NOT DISTINCT FROM g.supplier_id
+          AND p.stock_city IS NOT DISTINCT FROM g.stock_city
+          AND p.status IS NOT DISTINCT FROM g.status
+          AND p.currency IS NOT DISTINCT FROM g.currency
+        GROUP BY p.category_id, p.supplier_id, p.stock_city, p.status, p.currency;
+    END LOOP;
+
+    DELETE FROM product_statistics_pending WHERE txid = txid_current();
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- a trigger with transition tables fires on one event
+DROP TRIGGER IF EXISTS products_queue_statistics_insert ON products;
+CREATE TRIGGER products_queue_statistics_insert
+    AFTER INSERT ON products
+    REFERENCING NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION products_queue_statistics();
+
+DROP TRIGGER IF EXISTS products_queue_statistics_update ON products;
+CREATE TRIGGER products_queue_statistics_update
+    AFTER UPDATE ON products
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION products_queue_statistics();
+
+DROP TRIGGER IF EXISTS products_queue_statistics_delete ON products;
+CREATE TRIGGER products_queue_statistics_delete
+    AFTER DELETE ON products
+    REFERENCING OLD TABLE AS old_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION products_queue_statistics();
+
+-- a constraint trigger fires for each row, only the first one of the transaction finds queued groups
+DROP TRIGGER IF EXISTS products_refresh_statistics ON product_statistics_pending;
+CREATE CONSTRAINT TRIGGER products_refresh_statistics
+    AFTER INSERT ON product_statistics_pending
+    DEFERRABLE INITIALLY DEFERRED
+    FOR EACH ROW EXECUTE FUNCTION products_refresh_statistics();
+
+-- the incremental refresh of 007 is replaced by products_refresh_statistics
+DROP FUNCTION IF EXISTS refresh_product_statistics_group(products);
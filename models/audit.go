@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	Actor     string                 `json:"actor"`
+	ActorRole string                 `json:"actor_role"`
+	Action    string                 `json:"action"`
+	Entity    string                 `json:"entity"`
+	EntityID  string                 `json:"entity_id"`
+	Changes   map[string]FieldChange `json:"changes"`
+	ClientIP  string                 `json:"client_ip"`
+	RequestID string                 `json:"request_id"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type AuditRequest struct {
+	Actor    string `form:"actor"`
+	Entity   string `form:"entity"`
+	EntityID string `form:"entity_id"`
+	Action   string `form:"action"`
+	From     string `form:"from"`
+	To       string `form:"to"`
+	LastID   int64  `form:"last_id"`
+	PerPage  int    `form:"perPage"`
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"manage-products/apierrors"
+	"manage-products/jsonpatch"
+	"net/http"
+	"strings"
+)
+
+// @Summary      Patch product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  application/merge-patch+json (RFC 7386): the fields of the body are changed, null clears a field, e.g. {"category_id": null}
+// @Description  application/json-patch+json (RFC 6902): the operations are applied to the fields of ProductReplaceRequest, e.g. [{"op": "test", "path": "/price", "value": 10}, {"op": "remove", "path": "/supplier_id"}]
+// @Description  Reply 409 if a test operation fails, 422 if an operation targets an unknown field
+// @Description  Send the ETag of the product in If-Match, reply 412 if the product was modified since
+// @Accept       application/merge-patch+json,application/json-patch+json
+// @Param        request  body  ProductReplaceRequest  true  "Merge patch or JSON patch"
+// @Param        id  path  int  true  "Product ID"
+// @Param        If-Match  header  string  true  "ETag of the product"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id [patch]
+func (h *ProductHandler) PatchProduct(c *gin.Context) {
+	var applyPatch func(doc []byte, patch []byte) ([]byte, error)
+	switch c.ContentType() {
+	case jsonpatch.MergePatchType, binding.MIMEJSON:
+		applyPatch = jsonpatch.MergePatch
+	case jsonpatch.JSONPatchType:
+		applyPatch = jsonpatch.Apply
+	default:
+		apierrors.Reply(c, apierrors.New(http.StatusUnsupportedMediaType, apierrors.CodeUnsupportedMediaType,
+			"Content-Type must be "+jsonpatch.MergePatchType+" or "+jsonpatch.JSONPatchType))
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		apierrors.Reply(c, apierrors.BadRequest("invalid request: "+err.Error()))
+		return
+	}
+
+	h.updateProduct(c, "update", func(product Product) (ProductUpdateRequest, error) {
+		doc, err := json.Marshal(replaceRequestOf(product))
+		if err != nil {
+			return ProductUpdateRequest{}, apierrors.Internal(err, "have error when patch product")
+		}
+
+		patched, err := applyPatch(doc, patch)
+		if err != nil {
+			return ProductUpdateRequest{}, patchError(err)
+		}
+
+		req, err := decodeReplaceRequest(patched)
+		if err != nil {
+			return ProductUpdateRequest{}, err
+		}
+		return req.changes(), nil
+	})
+}
+
+// replaceRequestOf is the editable fields of a product, the document patched by PatchProduct
+func replaceRequestOf(product Product) ProductReplaceRequest {
+	return ProductReplaceRequest{
+		Name:       product.Name,
+		Reference:  product.Reference,
+		Status:     product.Status,
+		CategoryID: product.CategoryID,
+		Price:      product.Price,
+		Currency:   product.Currency,
+		StockCity:  product.StockCity,
+		SupplierID: product.SupplierID,
+		Quantity:   product.Quantity,
+		Attributes: product.Attributes,
+	}
+}
+
+// changes sets every field, the empty ones are cleared and an empty currency is the currency of the supplier
+func (req ProductReplaceRequest) changes() ProductUpdateRequest {
+	return ProductUpdateRequest{
+		Name:       &req.Name,
+		Reference:  &req.Reference,
+		Status:     &req.Status,
+		CategoryID: &req.CategoryID,
+		Price:      &req.Price,
+		Currency:   &req.Currency,
+		StockCity:  &req.StockCity,
+		SupplierID: &req.SupplierID,
+		Quantity:   &req.Quantity,
+		Attributes: &req.Attributes,
+	}
+}
+
+// decodeReplaceRequest validates a patched document like the body of PUT, a field added by the patch is an error
+func decodeReplaceRequest(doc []byte) (ProductReplaceRequest, error) {
+	var req ProductReplaceRequest
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+			field = strings.Trim(field, `"`)
+			return req, apierrors.InvalidField(field, field+" is not a field of product")
+		}
+		return req, apierrors.FromBinding(err)
+	}
+
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return req, apierrors.FromBinding(err)
+	}
+	return req, nil
+}
+
+func patchError(err error) error {
+	switch {
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return apierrors.Conflict(err.Error())
+	case errors.Is(err, jsonpatch.ErrPathNotFound):
+		return apierrors.New(http.StatusUnprocessableEntity, apierrors.CodeValidationFailed, err.Error())
+	}
+	return apierrors.BadRequest(err.Error())
+}
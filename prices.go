@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/cache"
+	"manage-products/handlers"
+	"manage-products/middlewares"
+	"manage-products/models"
+	"manage-products/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// recordPriceChange saves the current price of the product in its price timeline, an error is logged and returned
+func recordPriceChange(db orm.DB, c *gin.Context, product *Product) error {
+	createdBy := systemActor
+	if c != nil {
+		createdBy, _ = middlewares.CurrentUser(c)
+	}
+
+	now := time.Now()
+	_, err := db.Model(&ProductPrice{
+		ProductID:     product.ID,
+		Price:         product.Price,
+		Currency:      product.Currency,
+		EffectiveFrom: product.UpdatedAt,
+		AppliedAt:     &now,
+		CreatedBy:     createdBy,
+		CreatedAt:     now,
+	}).Insert()
+	if err != nil {
+		fmt.Println(err)
+	}
+	return err
+}
+
+// @Summary      Get price timeline of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  All price changes sorted by effective date, scheduled changes have no applied_at
+// @Description  With date, only the price of the product at this date is returned, a scheduled change counts once it is applied
+// @Param        id  path  int  true  "Product ID"
+// @Param        date  query  string  false  "Date of the price (RFC3339 or YYYY-MM-DD)"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/prices [get]
+func (h *ProductHandler) GetProductPrices(c *gin.Context) {
+	id := c.Param("id")
+
+	if c.Query("date") != "" {
+		date, err := parseDate(c.Query("date"))
+		if err != nil {
+			apierrors.Reply(c, apierrors.InvalidParam("date", "date must be RFC3339 or YYYY-MM-DD"))
+			return
+		}
+
+		price := &ProductPrice{}
+		err = h.db.Model(price).
+			Where("product_id = ?", id).
+			Where("applied_at IS NOT NULL").
+			Where("effective_from <= ?", date).
+			Order("effective_from DESC", "id DESC").
+			Limit(1).
+			Select()
+		if err != nil {
+			if err == pg.ErrNoRows {
+				apierrors.Reply(c, apierrors.NotFound("product has no price at this date"))
+				return
+			}
+
+			apierrors.Reply(c, apierrors.FromDB(err, "have error when get product price"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"price": price,
+		})
+		return
+	}
+
+	prices := make([]ProductPrice, 0)
+	err := h.db.Model(&prices).Where("product_id = ?", id).Order("effective_from ASC", "id ASC").Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product prices"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"prices": prices,
+	})
+}
+
+// @Summary      Schedule price change
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The price is applied to the product automatically at effective_from, which must be in the future
+// @Param        request  body  ProductPriceScheduleRequest  true  "Price change"
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/prices [post]
+func (h *ProductHandler) ScheduleProductPrice(c *gin.Context) {
+	var req ProductPriceScheduleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	// a price of 0 is valid, required would reject it
+	if req.Price == nil {
+		apierrors.Reply(c, apierrors.Validation(apierrors.FieldError{Field: "price", Code: apierrors.FieldRequired, Message: "price is required"}))
+		return
+	}
+	if req.Price.Sign() < 0 {
+		apierrors.Reply(c, apierrors.InvalidField("price", "price must not be negative"))
+		return
+	}
+	if !req.EffectiveFrom.After(time.Now()) {
+		apierrors.Reply(c, apierrors.InvalidField("effective_from", "effective_from must be in the future, update the product to change its price now"))
+		return
+	}
+
+	id := c.Param("id")
+	product := &Product{ID: id}
+	err := h.db.Model(product).WherePK().Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = product.Currency
+	}
+	if !utils.IsCurrency(req.Currency) {
+		apierrors.Reply(c, apierrors.InvalidField("currency", "invalid currency"))
+		return
+	}
+
+	createdBy, _ := middlewares.CurrentUser(c)
+	price := &ProductPrice{
+		ProductID:     id,
+		Price:         *req.Price,
+		Currency:      req.Currency,
+		EffectiveFrom: req.EffectiveFrom,
+		CreatedBy:     createdBy,
+		CreatedAt:     time.Now(),
+	}
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(price).Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "schedule_price", Entity: "product", EntityID: id}, nil, price)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when schedule price"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":   "schedule price successfully",
+		"price": price,
+	})
+}
+
+// @Summary      Cancel scheduled price change
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Only a price change which is not applied yet can be cancelled
+// @Param        id  path  int  true  "Product ID"
+// @Param        price_id  path  int  true  "Price change ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/prices/:price_id [delete]
+func (h *ProductHandler) CancelProductPrice(c *gin.Context) {
+	id := c.Param("id")
+	price := &ProductPrice{}
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err := tx.Model(price).
+			Where("id = ?", c.Param("price_id")).
+			Where("product_id = ?", id).
+			Where("applied_at IS NULL").
+			Returning("*").
+			Delete()
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return apierrors.NotFound("scheduled price not found")
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "cancel_price", Entity: "product", EntityID: id}, price, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when cancel price"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "cancel price successfully",
+	})
+}
+
+// startPriceScheduler applies the scheduled price changes once their effective date is reached
+func startPriceScheduler(db *pg.DB, responseCache cache.Cache) {
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			applied, err := applyScheduledPrices(db)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			if applied > 0 {
+				invalidateCache(responseCache, cache.Products)
+			}
+		}
+	}()
+}
+
+// applyScheduledPrices returns the number of prices applied
+func applyScheduledPrices(db *pg.DB) (int, error) {
+	applied := 0
+	err := db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		now := time.Now()
+
+		// SKIP LOCKED lets several instances of the api run the scheduler at the same time
+		prices := make([]ProductPrice, 0)
+		err := tx.Model(&prices).
+			Where("applied_at IS NULL").
+			Where("effective_from <= ?", now).
+			Order("effective_from ASC", "id ASC").
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil {
+			return err
+		}
+
+		for i := range prices {
+			price := &prices[i]
+
+			// the lock keeps the version read here until the update, a concurrent write waits instead of being lost
+			product := &Product{ID: price.ProductID}
+			if err := tx.Model(product).WherePK().For("UPDATE").Select(); err != nil {
+				if err == pg.ErrNoRows {
+					// the product is in the trash, the price stays scheduled until it is restored
+					continue
+				}
+				return err
+			}
+
+			before := *product
+			product.Price = price.Price
+			product.Currency = price.Currency
+			product.Version++
+			product.UpdatedAt = now
+			if _, err := tx.Model(product).Column("price", "currency", "version", "updated_at").WherePK().Update(); err != nil {
+				return err
+			}
+
+			price.AppliedAt = &now
+			if _, err := tx.Model(price).Column("applied_at").WherePK().Update(); err != nil {
+				return err
+			}
+
+			err := handlers.RecordAudit(tx, nil, models.AuditEvent{
+				Actor: systemActor, Action: "apply_price", Entity: "product", EntityID: product.ID,
+			}, before, product)
+			if err != nil {
+				return err
+			}
+			if err := recordProductVersion(tx, nil, "apply_price", product); err != nil {
+				return err
+			}
+			applied++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// parseDate accepts a RFC3339 time or a date only
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -2,17 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
 	"github.com/jung-kurt/gofpdf"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/storage"
+	"manage-products/utils"
 	"net/http"
 	"strings"
 	"time"
 )
 
 type ProductHandler struct {
-	db *pg.DB
+	db      *pg.DB
+	storage storage.Storage
 }
 
 // @Summary      Get products
@@ -21,65 +29,54 @@ type ProductHandler struct {
 // @Param        perPage  		query  int     false   "Number of products per page"
 // @Param        field    		query  string  false   "Field to filter by (e.g., supplier, category)"
 // @Param        values   		query  array   false   "Values of field"
+// @Param        include_descendants  query  bool  false  "With field=category, match the subcategories too"
+// @Param        tags  		query  array   false   "Names of tags, the products have every tag"
+// @Param        collections  	query  array   false   "Names of collections, the products are in every collection"
 // @Param        last_reference query  string  false   "The last reference of previous page"
+// @Param        currency  		query  string  false   "Currency of the prices (e.g., EUR, USD)"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products [get]
 func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var req ProductRequest
-	c.BindQuery(&req)
+	if err := c.ShouldBindQuery(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
 
 	if req.PerPage <= 0 {
 		req.PerPage = 10
 	}
 
-	/*
-		 Parse dynamic filters:
-			- field: field needed to query
-			- values: values needed to query
-			- example: reference = ["PROD-202401-029", "PROD-202401-039"]
-	*/
-	params := make(map[string]interface{})
-	for key, values := range c.Request.URL.Query() {
-		if key == "field" && len(values) > 0 {
-			params[key] = values[0]
-		}
-		if key == "values" {
-			params[key] = values
-		}
-	}
-
 	products := make([]Product, 0)
 	query := h.db.Model(&products)
 
-	fieldToQuery := params["field"].(string)
-	values := params["values"]
-	if fieldToQuery != "" && values != nil {
-		if fieldToQuery == "name" {
-			fieldToQuery = "product.name"
-		}
-		if fieldToQuery == "category" {
-			fieldToQuery = "category.name"
-		}
-		if fieldToQuery == "supplier" {
-			fieldToQuery = "supplier.name"
-		}
-		query.Where(fmt.Sprintf("%v IN (?)", fieldToQuery), pg.In(values))
+	if err := applyProductFilters(c, query); err != nil {
+		apierrors.Reply(c, err)
+		return
 	}
 
 	if req.LastReference != "" {
 		query.Where("reference < ?", req.LastReference)
 	}
+	applyAttributeFilters(c, query)
+	applyTagFilters(c, query)
+	if req.Variants {
+		query.Relation("Variants", orderVariants)
+	}
 
 	err := query.Relation("Category").Relation("Supplier").
+		Relation("Tags", orderTags).Relation("Collections", orderCollections).
 		Order("reference DESC").
 		Limit(req.PerPage).
 		Select()
 
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get products",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get products"))
+		return
+	}
+
+	if !h.convertProductsToRequestedCurrency(c, products) {
 		return
 	}
 
@@ -88,71 +85,178 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 	})
 }
 
+// productFilterColumns are the fields accepted by the dynamic filters and their column in a query joining category and supplier
+var productFilterColumns = map[string]string{
+	"name":       "product.name",
+	"reference":  "product.reference",
+	"status":     "product.status",
+	"category":   "category.name",
+	"supplier":   "supplier.name",
+	"stock_city": "product.stock_city",
+	"currency":   "product.currency",
+}
+
+/*
+applyProductFilters parses the dynamic filters of the request and adds them to a query on products:
+  - field: field needed to query
+  - values: values needed to query
+  - include_descendants: true to match the subcategories of the categories in values
+  - example: reference = ["PROD-202401-029", "PROD-202401-039"]
+*/
+func applyProductFilters(c *gin.Context, query *orm.Query) error {
+	return applyProductFilter(query, c.Query("field"), c.QueryArray("values"), c.Query("include_descendants") == "true")
+}
+
+/*
+applyProductFilter adds the filter field IN values to a query on products, nothing if values is empty.
+With includeDescendants, a filter by category also matches the products of the subcategories.
+*/
+func applyProductFilter(query *orm.Query, field string, values []string, includeDescendants bool) error {
+	if field == "" || len(values) == 0 {
+		return nil
+	}
+
+	column, ok := productFilterColumns[field]
+	if !ok {
+		return apierrors.InvalidField("field", fmt.Sprintf("can not filter by %v", field))
+	}
+
+	if field == "category" && includeDescendants {
+		query.Where("product.category_id IN (?)", categorySubtreeQuery(values))
+		return nil
+	}
+
+	query.Where(fmt.Sprintf("%v IN (?)", column), pg.In(values))
+	return nil
+}
+
 // @Summary      Create product
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Without reference, the next reference of the current period is generated, e.g. PROD-202401-029
+// @Description  Reply 409 with the existing product if the reference is taken
 // @Param        request  body  ProductCreateRequest  true  "Product filter request"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products [post]
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var req ProductCreateRequest
-	if err := c.Bind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-			"msg":   "invalid request body",
-		})
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	// the product, its audit and its history are written together
+	var product *Product
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		var err error
+		product, err = h.insertProduct(tx, c, req)
+		return err
+	})
+	if err != nil {
+		apierrors.Reply(c, err)
 		return
 	}
 
-	_, err := h.db.Model(&Product{
+	c.JSON(http.StatusOK, gin.H{
+		"msg":     "create product successfully",
+		"product": product,
+	})
+}
+
+// insertProduct creates a product and records its history, db is the transaction of the request
+func (h *ProductHandler) insertProduct(db orm.DB, c *gin.Context, req ProductCreateRequest) (*Product, error) {
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = h.defaultCurrency(req.SupplierID)
+	}
+	if !utils.IsCurrency(req.Currency) {
+		return nil, apierrors.InvalidField("currency", "invalid currency")
+	}
+
+	format := currentReferenceFormat()
+	generated := req.Reference == ""
+	if !generated {
+		if err := checkReference(format, req.Reference); err != nil {
+			return nil, err
+		}
+		if err := reserveReference(db, format, req.Reference); err != nil {
+			return nil, apierrors.FromDB(err, "have error when reserve reference")
+		}
+	}
+
+	product := &Product{
 		Name:       req.Name,
 		Reference:  req.Reference,
 		Status:     req.Status,
 		CategoryID: req.CategoryID,
 		Price:      req.Price,
+		Currency:   req.Currency,
 		StockCity:  req.StockCity,
 		SupplierID: req.SupplierID,
 		Quantity:   req.Quantity,
-	}).Insert()
-
-	if err != nil {
-		if strings.Contains(err.Error(), "products_category_id_fkey") {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "category_id not exists",
-			})
-			return
+		Attributes: req.Attributes,
+		Version:    1,
+		UpdatedAt:  time.Now(),
+	}
+
+	if err := validateProductAttributes(db, product); err != nil {
+		return nil, err
+	}
+
+	if generated {
+		// a generated reference can be taken by a product created before the generator knew it, the next one is tried.
+		// DO NOTHING instead of an error keeps a transaction usable for the next attempt
+		for attempt := 1; ; attempt++ {
+			reference, err := nextReference(db, format)
+			if err != nil {
+				return nil, apierrors.FromDB(err, "have error when generate reference")
+			}
+			product.Reference = reference
+
+			res, err := db.Model(product).OnConflict("(reference) DO NOTHING").Insert()
+			if err != nil {
+				return nil, apierrors.FromDB(err, "have error when create product")
+			}
+			if res.RowsAffected() > 0 {
+				break
+			}
+			if attempt == 3 {
+				return nil, apierrors.Conflict("can not generate a free reference, retry the request")
+			}
 		}
-
-		if strings.Contains(err.Error(), "products_supplier_id_fkey") {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "supplier_id not exists",
-			})
-			return
+	} else if _, err := db.Model(product).Insert(); err != nil {
+		if isReferenceConflict(err) {
+			return nil, h.referenceConflict(err, product.Reference)
 		}
 
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when create product",
-		})
-		return
+		return nil, apierrors.FromDB(err, "have error when create product")
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"msg": "create product successfully",
-	})
+	// a failed insert aborts the transaction, so the errors of the history are returned
+	err := handlers.RecordAudit(db, c, models.AuditEvent{Action: "create", Entity: "product", EntityID: product.ID}, nil, product)
+	if err != nil {
+		return nil, apierrors.FromDB(err, "have error when record audit")
+	}
+	if err := recordProductVersion(db, c, "create", product); err != nil {
+		return nil, apierrors.FromDB(err, "have error when record product version")
+	}
+	if err := recordPriceChange(db, c, product); err != nil {
+		return nil, apierrors.FromDB(err, "have error when record price change")
+	}
+
+	return product, nil
 }
 
 // @Summary      Get all categories of products
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/categories [get]
 func (h *ProductHandler) GetCategories(c *gin.Context) {
 	categories := make([]Category, 0)
 	err := h.db.Model(&categories).Select()
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get categories",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get categories"))
 		return
 	}
 
@@ -164,15 +268,13 @@ func (h *ProductHandler) GetCategories(c *gin.Context) {
 // @Summary      Get all suppliers of products
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/suppliers [get]
 func (h *ProductHandler) GetSuppliers(c *gin.Context) {
 	suppliers := make([]Supplier, 0)
 	err := h.db.Model(&suppliers).Select()
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get suppliers",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get suppliers"))
 		return
 	}
 
@@ -181,36 +283,133 @@ func (h *ProductHandler) GetSuppliers(c *gin.Context) {
 	})
 }
 
-// @Summary      Update product
+// @Summary      Get product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The ETag header of the response must be sent back in If-Match to update or delete the product
+// @Description  With as_of, the product is rebuilt from its history as it was at this time
+// @Param        id  path  int  true  "Product ID"
+// @Param        as_of  query  string  false  "Time of the point-in-time view (RFC3339)"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id [get]
+func (h *ProductHandler) GetProduct(c *gin.Context) {
+	if c.Query("as_of") != "" {
+		h.getProductAsOf(c)
+		return
+	}
+
+	id := c.Param("id")
+	product := &Product{ID: id}
+	err := h.db.Model(product).WherePK().Relation("Category").Relation("Supplier").Relation("Variants", orderVariants).
+		Relation("Tags", orderTags).Relation("Collections", orderCollections).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	c.Header("ETag", productETag(product))
+	c.JSON(http.StatusOK, gin.H{
+		"product": product,
+	})
+}
+
+// @Summary      Replace product
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
-// @Param        request  body  ProductUpdateRequest  true  "Product filter request"
+// @Description  Every field is replaced, a missing field is cleared, use PATCH to change some fields
+// @Description  Send the ETag of the product in If-Match, reply 412 if the product was modified since
+// @Description  Reply 409 with the existing product if the reference is taken
+// @Param        request  body  ProductReplaceRequest  true  "Product filter request"
 // @Param        id  path  int  true  "Product ID"
+// @Param        If-Match  header  string  true  "ETag of the product"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/:id [put]
 func (h *ProductHandler) UpdateProduct(c *gin.Context) {
-	var req ProductUpdateRequest
-	if err := c.Bind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-			"msg":   "invalid request body",
-		})
+	var req ProductReplaceRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	h.updateProduct(c, "update", func(product Product) (ProductUpdateRequest, error) {
+		return req.changes(), nil
+	})
+}
+
+/*
+updateProduct applies the non nil fields returned by changes to the product of the path, action is saved in audit and history.
+changes receives the product checked against If-Match, its error is replied.
+*/
+func (h *ProductHandler) updateProduct(c *gin.Context, action string, changes func(product Product) (ProductUpdateRequest, error)) {
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
 		return
 	}
 
 	id := c.Param("id")
 	product := &Product{ID: id}
 	if err := h.db.Model(product).WherePK().Select(); err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusNotFound, gin.H{
-			"msg": "product not found",
-		})
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	if expectedVersion != anyVersion && expectedVersion != product.Version {
+		versionMismatch(c, product)
+		return
+	}
+	req, err := changes(*product)
+	if err != nil {
+		apierrors.Reply(c, err)
 		return
 	}
 
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		return h.saveProduct(tx, c, product, req, action)
+	})
+	if err != nil {
+		replyProductError(c, err)
+		return
+	}
+
+	c.Header("ETag", productETag(product))
+	c.JSON(http.StatusOK, gin.H{
+		"msg": action + " product successfully",
+	})
+}
+
+/*
+saveProduct applies the non nil fields of req to product and records its history, db is the transaction of the request.
+The update is conditional on the version of product, it returns a 412 if someone wrote the product since it was read.
+*/
+func (h *ProductHandler) saveProduct(db orm.DB, c *gin.Context, product *Product, req ProductUpdateRequest, action string) error {
+	currentVersion := product.Version
+	before := *product
+
 	if req.Name != nil {
 		product.Name = *req.Name
 	}
-	if req.Reference != nil {
+	if req.Reference != nil && *req.Reference != product.Reference {
+		// a revert can bring back a reference older than the format
+		if action != "revert" {
+			format := currentReferenceFormat()
+			if err := checkReference(format, *req.Reference); err != nil {
+				return err
+			}
+			if err := reserveReference(db, format, *req.Reference); err != nil {
+				return apierrors.FromDB(err, "have error when reserve reference")
+			}
+		}
 		product.Reference = *req.Reference
 	}
 	if req.Status != nil {
@@ -231,57 +430,97 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 	if req.Quantity != nil {
 		product.Quantity = *req.Quantity
 	}
+	if req.Attributes != nil {
+		product.Attributes = *req.Attributes
+	}
+	if req.Currency != nil {
+		currency := strings.ToUpper(*req.Currency)
+		if currency == "" {
+			currency = h.defaultCurrency(product.SupplierID)
+		}
+		if !utils.IsCurrency(currency) {
+			return apierrors.InvalidField("currency", "invalid currency")
+		}
+		product.Currency = currency
+	}
+	// a revert brings back attributes which may not follow the current schema of the category
+	if action != "revert" {
+		if err := validateProductAttributes(db, product); err != nil {
+			return err
+		}
+	} else if product.Attributes == nil {
+		product.Attributes = make(map[string]interface{})
+	}
+	product.Version = currentVersion + 1
+	product.UpdatedAt = time.Now()
 
-	_, err := h.db.Model(product).WherePK().Update()
+	// the version condition makes the update a no-op if someone wrote the product after our select
+	res, err := db.Model(product).WherePK().Where("version = ?", currentVersion).Update()
 	if err != nil {
-		if strings.Contains(err.Error(), "products_category_id_fkey") {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "category_id not exists",
-			})
-			return
+		if isReferenceConflict(err) {
+			return h.referenceConflict(err, product.Reference)
 		}
 
-		if strings.Contains(err.Error(), "products_supplier_id_fkey") {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "supplier_id not exists",
-			})
-			return
-		}
+		return apierrors.FromDB(err, "have error when update product")
+	}
 
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when update product",
-		})
-		return
+	if res.RowsAffected() == 0 {
+		return versionMismatchError(db, product.ID)
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"msg": "update product successfully",
-	})
+	// in a transaction a failed insert aborts it, so the errors of the history are returned instead of only logged
+	err = handlers.RecordAudit(db, c, models.AuditEvent{Action: action, Entity: "product", EntityID: product.ID}, before, product)
+	if err != nil {
+		return apierrors.FromDB(err, "have error when record audit")
+	}
+	if err := recordProductVersion(db, c, action, product); err != nil {
+		return apierrors.FromDB(err, "have error when record product version")
+	}
+	if !product.Price.Equal(before.Price) || product.Currency != before.Currency {
+		if err := recordPriceChange(db, c, product); err != nil {
+			return apierrors.FromDB(err, "have error when record price change")
+		}
+	}
+
+	return nil
 }
 
 // @Summary      Delete product
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The product is moved to the trash, it can be restored with /products/:id/restore
 // @Param        id  path  int  true  "Product ID"
+// @Param        If-Match  header  string  true  "ETag of the product"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/:id [delete]
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	id := c.Param("id")
 	product := &Product{ID: id}
 	if err := h.db.Model(product).WherePK().Select(); err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusNotFound, gin.H{
-			"msg": "product not found",
-		})
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	if expectedVersion != anyVersion && expectedVersion != product.Version {
+		versionMismatch(c, product)
 		return
 	}
 
-	_, err := h.db.Model(product).WherePK().Delete()
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		return removeProduct(tx, c, product)
+	})
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when delete product",
-		})
+		replyProductError(c, err)
 		return
 	}
 
@@ -290,22 +529,75 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 	})
 }
 
+// removeProduct moves product to the trash, it can be restored until it is purged, db is the transaction of the request
+func removeProduct(db orm.DB, c *gin.Context, product *Product) error {
+	currentVersion := product.Version
+	before := *product
+	now := time.Now()
+	product.DeletedAt = &now
+	product.Version = currentVersion + 1
+	product.UpdatedAt = now
+
+	res, err := db.Model(product).Column("deleted_at", "version", "updated_at").
+		WherePK().Where("version = ?", currentVersion).Update()
+	if err != nil {
+		return apierrors.FromDB(err, "have error when delete product")
+	}
+
+	if res.RowsAffected() == 0 {
+		return versionMismatchError(db, product.ID)
+	}
+
+	err = handlers.RecordAudit(db, c, models.AuditEvent{Action: "delete", Entity: "product", EntityID: product.ID}, before, product)
+	if err != nil {
+		return apierrors.FromDB(err, "have error when record audit")
+	}
+	if err := recordProductVersion(db, c, "delete", product); err != nil {
+		return apierrors.FromDB(err, "have error when record product version")
+	}
+
+	return nil
+}
+
 // @Summary      Statistics products per category
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Same as /api/statistics?group_by=category&metrics=count
+// @Description  The X-Computed-At header is the time the statistics were last computed
+// @Description  With rollup=true, every category of the tree has its depth and rollup_total, the products of the category and of its descendants
+// @Param        rollup  		query  bool    false   "Roll-up the totals at each level of the category tree"
+// @Param        format  		query  string  false   "json (by default), png or svg"
+// @Param        chart  		query  string  false   "Chart of png and svg: bar (by default) or pie"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /api/statistics/products-per-category [get]
 func (h *ProductHandler) StatisticsProductsPerCategory(c *gin.Context) {
-	rsp := make([]ProductsPerCategoryResponse, 0)
+	if c.Query("rollup") == "true" {
+		h.statisticsCategoryRollup(c)
+		return
+	}
 
-	err := h.db.Model(&Product{}).
-		Join("JOIN categories ON categories.id = product.category_id").
-		ColumnExpr("categories.name AS category_name, COUNT(*) AS total_products").
-		Group("categories.name").Select(&rsp)
+	rows, _, err := h.runStatistics(c, statisticsRequest{Dimensions: []string{"category"}, Metrics: []string{"count"}})
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when statistic products by category",
-		})
+		apierrors.Reply(c, err)
+		return
+	}
+	statisticsComputedAt(c, rows)
+
+	rsp := make([]ProductsPerCategoryResponse, 0, len(rows))
+	for _, row := range rows {
+		// products without category are not counted
+		if row.Category != nil {
+			rsp = append(rsp, ProductsPerCategoryResponse{CategoryName: *row.Category, TotalProducts: row.Count})
+		}
+	}
+
+	labels := make([]string, 0, len(rsp))
+	values := make([]float64, 0, len(rsp))
+	for _, item := range rsp {
+		labels = append(labels, item.CategoryName)
+		values = append(values, float64(item.TotalProducts))
+	}
+	if replyStatisticsChart(c, "Products per category", labels, values) {
 		return
 	}
 
@@ -314,20 +606,43 @@ func (h *ProductHandler) StatisticsProductsPerCategory(c *gin.Context) {
 
 // @Summary      Statistics products per supplier
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Same as /api/statistics?group_by=supplier&metrics=count
+// @Description  With the scorecard of the supplier over all its receipts, see /api/statistics/suppliers/:id/scorecard
+// @Description  The X-Computed-At header is the time the statistics were last computed
+// @Param        format  		query  string  false   "json (by default), png or svg"
+// @Param        chart  		query  string  false   "Chart of png and svg: bar (by default) or pie"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /api/statistics/products-per-supplier [get]
 func (h *ProductHandler) StatisticsProductsPerSupplier(c *gin.Context) {
-	rsp := make([]ProductsPerSupplierResponse, 0)
+	rows, _, err := h.runStatistics(c, statisticsRequest{Dimensions: []string{"supplier"}, Metrics: []string{"count"}})
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	statisticsComputedAt(c, rows)
 
-	err := h.db.Model(&Product{}).
-		Join("JOIN suppliers ON suppliers.id = product.supplier_id").
-		ColumnExpr("suppliers.name AS supplier_name, COUNT(*) AS total_products").
-		Group("suppliers.name").Select(&rsp)
+	scorecards, err := supplierScorecards(h.db)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when statistic products by supplier",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when compute scorecards of suppliers"))
+		return
+	}
+
+	rsp := make([]ProductsPerSupplierResponse, 0, len(rows))
+	for _, row := range rows {
+		// products without supplier are not counted
+		if row.Supplier != nil {
+			rsp = append(rsp, ProductsPerSupplierResponse{SupplierName: *row.Supplier, TotalProducts: row.Count, Scorecard: scorecards[*row.Supplier]})
+		}
+	}
+
+	labels := make([]string, 0, len(rsp))
+	values := make([]float64, 0, len(rsp))
+	for _, item := range rsp {
+		labels = append(labels, item.SupplierName)
+		values = append(values, float64(item.TotalProducts))
+	}
+	if replyStatisticsChart(c, "Products per supplier", labels, values) {
 		return
 	}
 
@@ -338,7 +653,13 @@ func (h *ProductHandler) StatisticsProductsPerSupplier(c *gin.Context) {
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
 // @Param        field    		query  string  false   "Field to filter by (e.g., supplier, category)"
 // @Param        values   		query  array   false   "Values of field"
+// @Param        include_descendants  query  bool  false  "With field=category, match the subcategories too"
+// @Param        tags  		query  array   false   "Names of tags, the products have every tag"
+// @Param        collections  	query  array   false   "Names of collections, the products are in every collection"
+// @Param        currency  		query  string  false   "Currency of the prices (e.g., EUR, USD)"
+// @Param        charts  		query  string  false   "Comma separated dimensions, a pie chart of products per dimension is drawn above the table"
 // @Success      200 {file}  pdf
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/export [get]
 func (h *ProductHandler) ExportProduct(c *gin.Context) {
 	pdf := gofpdf.New("P", "mm", "A2", "")
@@ -349,56 +670,47 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 	pdf.Ln(10)
 
 	header := []string{
-		"Product Reference", "Product Name", "Date Added", "Status", "Product Category",
+		"Image", "Product Reference", "Product Name", "Date Added", "Status", "Product Category",
 		"Price", "Stock Location (City)", "Supplier", "Available Quantity",
 	}
 
 	products := make([]Product, 0)
 	query := h.db.Model(&products)
 
-	/*
-		 Parse dynamic filters:
-			- field: field needed to query
-			- values: values needed to query
-			- example: reference = ["PROD-202401-029", "PROD-202401-039"]
-	*/
-	params := make(map[string]interface{})
-	for key, values := range c.Request.URL.Query() {
-		if key == "field" && len(values) > 0 {
-			params[key] = values[0]
-		}
-		if key == "values" {
-			params[key] = values
-		}
+	if err := applyProductFilters(c, query); err != nil {
+		apierrors.Reply(c, err)
+		return
 	}
 
-	fieldToQuery, _ := params["field"].(string)
-	values := params["values"]
-	if fieldToQuery != "" && values != nil {
-		if fieldToQuery == "name" {
-			fieldToQuery = "product.name"
-		}
-		if fieldToQuery == "category" {
-			fieldToQuery = "category.name"
-		}
-		if fieldToQuery == "supplier" {
-			fieldToQuery = "supplier.name"
-		}
-		query.Where(fmt.Sprintf("%v IN (?)", fieldToQuery), pg.In(values))
+	applyAttributeFilters(c, query)
+	applyTagFilters(c, query)
+
+	err := query.Relation("Category").Relation("Supplier").Relation("Variants", orderVariants).Order("reference DESC").Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get products"))
+		return
+	}
+
+	if !h.convertProductsToRequestedCurrency(c, products) {
+		return
 	}
 
-	err := query.Relation("Category").Relation("Supplier").Order("reference DESC").Select()
+	if !h.drawStatisticsCharts(c, pdf) {
+		return
+	}
+
+	images, err := h.registerPrimaryThumbnails(c, pdf, products)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get products",
-		})
+		apierrors.Reply(c, err)
 		return
 	}
 
 	data := make([][]string, 0)
+	variantRows := make(map[int]bool)
+	imageRows := make(map[int]string)
 	for _, product := range products {
-		d := []string{product.Reference, product.Name, product.AddedDate.Format(time.DateOnly), product.Status}
+		imageRows[len(data)] = images[product.ID]
+		d := []string{"", product.Reference, product.Name, product.AddedDate.Format(time.DateOnly), product.Status}
 
 		if product.Category != nil {
 			d = append(d, product.Category.Name)
@@ -406,7 +718,7 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 			d = append(d, "")
 		}
 
-		d = append(d, fmt.Sprintf("%v", product.Price))
+		d = append(d, utils.FormatMoney(product.Price, product.Currency))
 		d = append(d, product.StockCity)
 
 		if product.Supplier != nil {
@@ -418,9 +730,19 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 		d = append(d, fmt.Sprintf("%v", product.Quantity))
 
 		data = append(data, d)
+
+		// the variants are indented under their product
+		for _, variant := range product.Variants {
+			variantRows[len(data)] = true
+			data = append(data, []string{
+				"", "    " + variant.Reference, "    " + variant.optionsLabel(), "", "", "",
+				utils.FormatMoney(variant.effectivePrice(&product), product.Currency), "", "",
+				fmt.Sprintf("%v", variant.Quantity),
+			})
+		}
 	}
 
-	colWidths := []float64{45, 60, 30, 30, 50, 30, 50, 40, 50}
+	colWidths := []float64{15, 45, 60, 30, 30, 50, 30, 50, 40, 50}
 
 	// Draw header of table
 	pdf.SetFont("Arial", "B", 12)
@@ -430,10 +752,21 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 	pdf.Ln(-1)
 
 	// Draw data of table
-	pdf.SetFont("Arial", "", 12)
-	for _, row := range data {
+	for r, row := range data {
+		pdf.SetFont("Arial", "", 12)
+		if variantRows[r] {
+			pdf.SetFont("Arial", "I", 11)
+		}
 		for i, col := range row {
-			pdf.CellFormat(colWidths[i], 10, col, "1", 0, "C", false, 0, "")
+			align := "C"
+			// the indent of a variant is only visible when aligned left
+			if variantRows[r] && i > 0 && i < 3 {
+				align = "L"
+			}
+			if i == 0 && imageRows[r] != "" {
+				drawCellImage(pdf, imageRows[r], colWidths[i], 10)
+			}
+			pdf.CellFormat(colWidths[i], 10, col, "1", 0, align, false, 0, "")
 		}
 		pdf.Ln(-1)
 	}
@@ -441,10 +774,7 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 	var pdfBuffer bytes.Buffer
 	err = pdf.Output(&pdfBuffer)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when export products",
-		})
+		apierrors.Reply(c, apierrors.Internal(err, "have error when export products"))
 		return
 	}
 
@@ -456,15 +786,13 @@ func (h *ProductHandler) ExportProduct(c *gin.Context) {
 // @Summary      Get all cities of products
 // @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /products/cities [get]
 func (h *ProductHandler) GetCities(c *gin.Context) {
 	cities := make([]string, 0)
 	err := h.db.Model(&Product{}).Column("stock_city").Group("stock_city").Select(&cities)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"msg": "have error when get cities",
-		})
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get cities"))
 		return
 	}
 
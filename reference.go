@@ -0,0 +1,129 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// referencePeriods are the accepted values of REFERENCE_PERIOD and the layout of their date part
+var referencePeriods = map[string]string{
+	"day":   "20060102",
+	"month": "200601",
+	"year":  "2006",
+}
+
+/*
+referenceFormat is the format of the product references: PREFIX-PERIOD-NUMBER, e.g. PROD-202401-029.
+  - REFERENCE_PREFIX: PROD by default
+  - REFERENCE_PERIOD: day, month (by default) or year, the numbers start again at 1 every period
+  - REFERENCE_DIGITS: min number of digits of the number, 3 by default
+*/
+type referenceFormat struct {
+	Prefix string
+	Layout string
+	Digits int
+}
+
+func currentReferenceFormat() referenceFormat {
+	format := referenceFormat{Prefix: "PROD", Layout: referencePeriods["month"], Digits: 3}
+	if prefix := strings.ToUpper(os.Getenv("REFERENCE_PREFIX")); prefix != "" {
+		format.Prefix = prefix
+	}
+	if layout, ok := referencePeriods[os.Getenv("REFERENCE_PERIOD")]; ok {
+		format.Layout = layout
+	}
+	if digits, err := strconv.Atoi(os.Getenv("REFERENCE_DIGITS")); err == nil && digits > 0 {
+		format.Digits = digits
+	}
+	return format
+}
+
+func (f referenceFormat) String() string {
+	layout := strings.NewReplacer("2006", "YYYY", "01", "MM", "02", "DD").Replace(f.Layout)
+	return fmt.Sprintf("%v-%v-%v", f.Prefix, layout, strings.Repeat("N", f.Digits))
+}
+
+func (f referenceFormat) format(period string, number int) string {
+	return fmt.Sprintf("%v-%v-%0*d", f.Prefix, period, f.Digits, number)
+}
+
+// parse returns the period and the number of a reference, ok is false if it does not follow the format
+func (f referenceFormat) parse(reference string) (period string, number int, ok bool) {
+	pattern := fmt.Sprintf(`^%v-(\d{%d})-(\d{%d,9})$`, regexp.QuoteMeta(f.Prefix), len(f.Layout), f.Digits)
+	match := regexp.MustCompile(pattern).FindStringSubmatch(reference)
+	if match == nil {
+		return "", 0, false
+	}
+	if _, err := time.Parse(f.Layout, match[1]); err != nil {
+		return "", 0, false
+	}
+
+	number, _ = strconv.Atoi(match[2])
+	return match[1], number, number > 0
+}
+
+// nextReference takes the next number of the current period, the upsert makes it safe under concurrent inserts
+func nextReference(db orm.DB, format referenceFormat) (string, error) {
+	period := time.Now().UTC().Format(format.Layout)
+
+	var number int
+	_, err := db.QueryOne(pg.Scan(&number), `
+		INSERT INTO product_reference_sequences (prefix, period, last_number) VALUES (?, ?, 1)
+		ON CONFLICT (prefix, period) DO UPDATE SET last_number = product_reference_sequences.last_number + 1
+		RETURNING last_number`, format.Prefix, period)
+	if err != nil {
+		return "", err
+	}
+
+	return format.format(period, number), nil
+}
+
+// reserveReference makes the generator continue after a reference chosen by a client
+func reserveReference(db orm.DB, format referenceFormat, reference string) error {
+	period, number, ok := format.parse(reference)
+	if !ok {
+		return nil
+	}
+
+	_, err := db.Exec(`
+		INSERT INTO product_reference_sequences (prefix, period, last_number) VALUES (?, ?, ?)
+		ON CONFLICT (prefix, period) DO UPDATE
+			SET last_number = GREATEST(product_reference_sequences.last_number, EXCLUDED.last_number)`,
+		format.Prefix, period, number)
+	return err
+}
+
+// checkReference returns a 422 if a reference sent by a client does not follow the format
+func checkReference(format referenceFormat, reference string) *apierrors.Error {
+	if _, _, ok := format.parse(reference); !ok {
+		return apierrors.InvalidField("reference", fmt.Sprintf("reference must follow the format %v", format))
+	}
+	return nil
+}
+
+func isReferenceConflict(err error) bool {
+	var pgErr pg.Error
+	return errors.As(err, &pgErr) && pgErr.Field('C') == "23505" && pgErr.Field('n') == "products_reference_key"
+}
+
+// referenceConflict is a 409 with the product which has the reference, it may be in the trash
+func (h *ProductHandler) referenceConflict(err error, reference string) *apierrors.Error {
+	apiErr := apierrors.FromDB(err, "have error when save product")
+	apiErr.Detail = "reference already exists"
+
+	// read outside of the transaction of the write, which is aborted by the conflict
+	existing := &Product{}
+	if selectErr := h.db.Model(existing).Where("reference = ?", reference).AllWithDeleted().Select(); selectErr == nil {
+		apiErr.With("product", existing)
+	}
+
+	return apiErr
+}
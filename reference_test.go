@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestReferenceFormat(t *testing.T) {
+	month := referenceFormat{Prefix: "PROD", Layout: referencePeriods["month"], Digits: 3}
+	day := referenceFormat{Prefix: "A.B", Layout: referencePeriods["day"], Digits: 2}
+
+	if got := month.String(); got != "PROD-YYYYMM-NNN" {
+		t.Errorf("got %v", got)
+	}
+	if got := month.format("202401", 29); got != "PROD-202401-029" {
+		t.Errorf("got %v", got)
+	}
+	if got := month.format("202401", 1234); got != "PROD-202401-1234" {
+		t.Errorf("got %v", got)
+	}
+
+	tests := []struct {
+		format     referenceFormat
+		reference  string
+		wantPeriod string
+		wantNumber int
+		wantOK     bool
+	}{
+		{format: month, reference: "PROD-202401-029", wantPeriod: "202401", wantNumber: 29, wantOK: true},
+		{format: month, reference: "PROD-202401-1234", wantPeriod: "202401", wantNumber: 1234, wantOK: true},
+		{format: month, reference: "PROD-202413-001"},
+		{format: month, reference: "PROD-202401-01"},
+		{format: month, reference: "PROD-202401-000"},
+		{format: month, reference: "PROD-202401-0000000001"},
+		{format: month, reference: "prod-202401-001"},
+		{format: month, reference: "PROD-202401-029-01"},
+		{format: day, reference: "A.B-20240229-07", wantPeriod: "20240229", wantNumber: 7, wantOK: true},
+		{format: day, reference: "A.B-20230229-07"},
+		// the dot of the prefix is not a wildcard
+		{format: day, reference: "AxB-20240101-07"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.reference, func(t *testing.T) {
+			period, number, ok := tt.format.parse(tt.reference)
+			if ok != tt.wantOK || ok && (period != tt.wantPeriod || number != tt.wantNumber) {
+				t.Errorf("got %v %v %v, want %v %v %v", period, number, ok, tt.wantPeriod, tt.wantNumber, tt.wantOK)
+			}
+			if err := checkReference(tt.format, tt.reference); (err == nil) != tt.wantOK {
+				t.Errorf("checkReference got %v", err)
+			}
+		})
+	}
+}
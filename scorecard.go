@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"net/http"
+	"time"
+)
+
+// supplierScorecardMetrics are the sql expressions of the fields of SupplierScorecardRow on the receipts of the suppliers
+var supplierScorecardMetrics = []string{
+	"COUNT(*) AS receipts",
+	"COALESCE(SUM(stock_movement.quantity), 0) AS received_quantity",
+	"COALESCE(SUM(stock_movement.ordered_quantity), 0) AS ordered_quantity",
+	// a receipt without expected date is not counted
+	"ROUND(AVG((stock_movement.created_at::DATE <= stock_movement.expected_at::DATE)::INT), 4) AS on_time_rate",
+	"ROUND(AVG(EXTRACT(EPOCH FROM stock_movement.created_at - stock_movement.ordered_at) / 86400)::NUMERIC, 2) AS avg_lead_time_days",
+	"ROUND(SUM(stock_movement.quantity)::NUMERIC / NULLIF(SUM(stock_movement.ordered_quantity), 0), 4) AS fill_rate",
+	"ROUND(AVG((stock_movement.unit_cost - stock_movement.list_unit_cost) / NULLIF(stock_movement.list_unit_cost, 0)), 4) AS price_variance",
+}
+
+// supplierReceiptsQuery returns a query computing the scorecard metrics on the receipts of the suppliers
+func supplierReceiptsQuery(db orm.DB) *orm.Query {
+	query := db.Model((*StockMovement)(nil)).
+		Where("stock_movement.kind = 'receipt'").
+		Where("stock_movement.supplier_id IS NOT NULL")
+	for _, metric := range supplierScorecardMetrics {
+		query.ColumnExpr(metric)
+	}
+	return query
+}
+
+// supplierScorecards returns the scorecard of every supplier by name, over all its receipts
+func supplierScorecards(db orm.DB) (map[string]*SupplierScorecardRow, error) {
+	rows := make([]SupplierScorecardRow, 0)
+	err := supplierReceiptsQuery(db).
+		ColumnExpr("supplier.name AS supplier").
+		Join("JOIN suppliers AS supplier ON supplier.id = stock_movement.supplier_id").
+		Group("supplier.name").
+		Select(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	scorecards := make(map[string]*SupplierScorecardRow, len(rows))
+	for i := range rows {
+		if rows[i].Supplier != nil {
+			scorecards[*rows[i].Supplier] = &rows[i]
+		}
+	}
+	return scorecards, nil
+}
+
+// @Summary      Scorecard of supplier
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Performance of the supplier on the receipts of the period with a purchase order, in total and per interval
+// @Description  on_time_rate: share delivered at the latest on the expected date, avg_lead_time_days: days from order to receipt
+// @Description  fill_rate: received / ordered quantity, price_variance: average of (paid - price list) / price list
+// @Description  A metric is null without receipts to compute it
+// @Param        id  			path   int     true    "Supplier ID"
+// @Param        interval  		query  string  false   "day, week or month (month by default)"
+// @Param        from    		query  string  false   "Start date (YYYY-MM-DD or RFC3339)"
+// @Param        to    			query  string  false   "End date (YYYY-MM-DD or RFC3339), today by default"
+// @Param        format  		query  string  false   "json (by default), png or svg, the chart shows the on-time rate"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /api/statistics/suppliers/:id/scorecard [get]
+func (h *ProductHandler) StatisticsSupplierScorecard(c *gin.Context) {
+	supplier := &Supplier{ID: c.Param("id")}
+	if err := h.db.Model(supplier).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("supplier not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get supplier"))
+		return
+	}
+
+	interval, from, to, buckets, apiErr := parseStatisticsBuckets(c, "month")
+	if apiErr != nil {
+		apierrors.Reply(c, apiErr)
+		return
+	}
+	end := addInterval(to, interval, 1)
+
+	period := func() *orm.Query {
+		return supplierReceiptsQuery(h.db).
+			Where("stock_movement.supplier_id = ?", supplier.ID).
+			Where("stock_movement.created_at >= ?", from).
+			Where("stock_movement.created_at < ?", end)
+	}
+
+	total := SupplierScorecardRow{}
+	if err := period().Select(&total); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when compute scorecard of supplier"))
+		return
+	}
+
+	rows := make([]SupplierScorecardRow, 0)
+	err := period().
+		ColumnExpr("date_trunc(?, stock_movement.created_at AT TIME ZONE 'UTC') AS bucket", interval).
+		GroupExpr("bucket").
+		Select(&rows)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when compute scorecard of supplier"))
+		return
+	}
+
+	byBucket := make(map[time.Time]SupplierScorecardRow, len(rows))
+	for _, row := range rows {
+		if row.Bucket != nil {
+			byBucket[row.Bucket.UTC()] = row
+		}
+	}
+
+	points := make([]SupplierScorecardBucket, 0, len(buckets))
+	labels := make([]string, 0, len(buckets))
+	values := make([]float64, 0, len(buckets))
+	for _, bucket := range buckets {
+		row := byBucket[bucket]
+		points = append(points, SupplierScorecardBucket{Start: bucket.Format(time.DateOnly), SupplierScorecardRow: row})
+
+		labels = append(labels, bucket.Format(time.DateOnly))
+		value := 0.0
+		if row.OnTimeRate != nil {
+			value = row.OnTimeRate.Float64()
+		}
+		values = append(values, value)
+	}
+	if replyStatisticsChart(c, fmt.Sprintf("On-time delivery rate of %v per %v", supplier.Name, interval), labels, values) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"supplier":  supplier,
+		"interval":  interval,
+		"from":      from.Format(time.DateOnly),
+		"to":        to.Format(time.DateOnly),
+		"scorecard": total,
+		"buckets":   points,
+	})
+}
@@ -0,0 +1,735 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/jung-kurt/gofpdf"
+	"manage-products/apierrors"
+	"manage-products/cache"
+	"manage-products/charts"
+	"manage-products/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// statisticsDimensions are the accepted values of group_by and their column in a query joining category and supplier
+var statisticsDimensions = map[string]string{
+	"category_id": "product.category_id",
+	"category":    "category.name",
+	"supplier":    "supplier.name",
+	"stock_city":  "product.stock_city",
+	"status":      "product.status",
+}
+
+/*
+statisticsMetrics are the accepted values of metrics and their sql expression on the products.
+  - ?rate is replaced by the exchange rate from the currency of the product to the requested currency
+*/
+var statisticsMetrics = map[string]string{
+	"count":           "COUNT(*)",
+	"sum_quantity":    "COALESCE(SUM(product.quantity), 0)",
+	"avg_quantity":    "AVG(product.quantity)",
+	"sum_stock_value": "COALESCE(SUM(product.price * ?rate * product.quantity), 0)",
+	"avg_price":       "AVG(product.price * ?rate)",
+	"min_price":       "MIN(product.price * ?rate)",
+	"max_price":       "MAX(product.price * ?rate)",
+}
+
+// materializedStatisticsMetrics are the sql expressions of statisticsMetrics on the rows of product_statistics
+var materializedStatisticsMetrics = map[string]string{
+	"count":           "SUM(product.count)",
+	"sum_quantity":    "COALESCE(SUM(product.sum_quantity), 0)",
+	"avg_quantity":    "SUM(product.sum_quantity)::NUMERIC / SUM(product.count)",
+	"sum_stock_value": "COALESCE(SUM(product.sum_stock_value * ?rate), 0)",
+	"avg_price":       "SUM(product.sum_price * ?rate) / SUM(product.count)",
+	"min_price":       "MIN(product.min_price * ?rate)",
+	"max_price":       "MAX(product.max_price * ?rate)",
+}
+
+// statisticsRequest describes a statistics query: products are grouped by dimensions and metrics are computed per group
+type statisticsRequest struct {
+	Dimensions []string
+	Metrics    []string
+	Currency   string
+	Sort       []string
+	Page       int
+	PerPage    int
+}
+
+// parseList parses a comma separated list of names which must be keys of allowed, defaultValue is used when it is empty
+func parseList(value string, allowed map[string]string, defaultValue []string) ([]string, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	names := make([]string, 0)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := allowed[name]; !ok {
+			return nil, fmt.Errorf("unknown %v", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// parseGroupBy parses a comma separated list of dimensions, defaultDimension is used when it is empty
+func parseGroupBy(groupBy string, defaultDimension string) ([]string, error) {
+	dimensions, err := parseList(groupBy, statisticsDimensions, []string{defaultDimension})
+	if err != nil {
+		return nil, apierrors.InvalidParam("group_by", fmt.Sprintf("can not group by: %v", err))
+	}
+	return dimensions, nil
+}
+
+func (req statisticsRequest) hasPriceMetric() bool {
+	for _, metric := range req.Metrics {
+		if strings.Contains(statisticsMetrics[metric], "?rate") {
+			return true
+		}
+	}
+	return false
+}
+
+func (req statisticsRequest) has(name string) bool {
+	for _, selected := range append(append([]string{}, req.Dimensions...), req.Metrics...) {
+		if selected == name {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+runStatistics runs a statistics query on the products matching the dynamic filters of the request.
+  - The rows of product_statistics are grouped instead of the products, except when filtering by name or reference
+  - The error is an *apierrors.Error
+  - total is the number of groups without pagination
+*/
+func (h *ProductHandler) runStatistics(c *gin.Context, req statisticsRequest) ([]StatisticsRow, int, error) {
+	db := h.db
+	if req.hasPriceMetric() {
+		if !utils.IsCurrency(req.Currency) {
+			return nil, 0, apierrors.InvalidParam("currency", "invalid currency")
+		}
+
+		rates, err := loadExchangeRates(h.db, time.Now())
+		if err != nil {
+			return nil, 0, apierrors.FromDB(err, "have error when get exchange rates")
+		}
+
+		rate, err := rates.rateToCurrency(h.db, req.Currency)
+		if err != nil {
+			return nil, 0, err
+		}
+		db = h.db.WithParam("rate", rate)
+	}
+
+	// product_statistics has the same columns as products for the dimensions and the filters but name and reference
+	metrics := materializedStatisticsMetrics
+	query := db.Model(&ProductStatistics{}).ColumnExpr("MAX(product.computed_at) AS computed_at")
+	if statisticsNeedProducts(c) {
+		metrics = statisticsMetrics
+		query = db.Model(&Product{}).ColumnExpr("NOW() AS computed_at")
+	}
+	query.
+		Join("LEFT JOIN categories AS category ON category.id = product.category_id").
+		Join("LEFT JOIN suppliers AS supplier ON supplier.id = product.supplier_id")
+
+	// the same filters as GetProducts
+	if err := applyProductFilters(c, query); err != nil {
+		return nil, 0, err
+	}
+	applyAttributeFilters(c, query)
+	applyTagFilters(c, query)
+
+	for _, dimension := range req.Dimensions {
+		column := statisticsDimensions[dimension]
+		query.ColumnExpr(fmt.Sprintf("%v AS %v", column, dimension)).Group(column)
+	}
+	for _, metric := range req.Metrics {
+		query.ColumnExpr(fmt.Sprintf("%v AS %v", metrics[metric], metric))
+	}
+
+	for _, sort := range req.Sort {
+		direction := "ASC"
+		if strings.HasPrefix(sort, "-") {
+			direction = "DESC"
+			sort = strings.TrimPrefix(sort, "-")
+		}
+		if !req.has(sort) {
+			return nil, 0, apierrors.InvalidParam("sort", fmt.Sprintf("can not sort by %v, it must be a selected dimension or metric", sort))
+		}
+		query.OrderExpr(fmt.Sprintf("%v %v NULLS LAST", sort, direction))
+	}
+	for _, dimension := range req.Dimensions {
+		query.OrderExpr(fmt.Sprintf("%v ASC NULLS LAST", dimension))
+	}
+
+	if req.PerPage > 0 {
+		query.Limit(req.PerPage).Offset((req.Page - 1) * req.PerPage)
+	}
+
+	rows := make([]StatisticsRow, 0)
+	total, err := query.SelectAndCount(&rows)
+	if err != nil {
+		return nil, 0, apierrors.FromDB(err, "have error when statistic products")
+	}
+
+	return rows, total, nil
+}
+
+// statisticsNeedProducts tells if the filters of the request are on columns or tables which are not in product_statistics
+func statisticsNeedProducts(c *gin.Context) bool {
+	field := c.Query("field")
+	return field == "name" || field == "reference" ||
+		len(c.QueryArray("tags")) > 0 || len(c.QueryArray("collections")) > 0 || len(c.QueryMap("attributes")) > 0
+}
+
+// statisticsComputedAt returns the time the rows were last computed and sets it in the X-Computed-At header
+func statisticsComputedAt(c *gin.Context, rows []StatisticsRow) time.Time {
+	var computedAt time.Time
+	for _, row := range rows {
+		if row.ComputedAt.After(computedAt) {
+			computedAt = row.ComputedAt
+		}
+	}
+	if computedAt.IsZero() {
+		computedAt = time.Now()
+	}
+
+	c.Header("X-Computed-At", computedAt.UTC().Format(time.RFC3339))
+	return computedAt
+}
+
+/*
+startStatisticsRefresher computes product_statistics again every hour.
+The groups are already refreshed at the commit of every write of a product (migrations/018), this only repairs
+the statistics after writes which bypass the trigger.
+*/
+func startStatisticsRefresher(db *pg.DB, responseCache cache.Cache) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if _, err := db.Exec("SELECT refresh_product_statistics()"); err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			invalidateCache(responseCache, cache.Products)
+		}
+	}()
+}
+
+func (row StatisticsRow) dimension(name string) *string {
+	switch name {
+	case "category_id":
+		return row.CategoryID
+	case "category":
+		return row.Category
+	case "supplier":
+		return row.Supplier
+	case "stock_city":
+		return row.StockCity
+	case "status":
+		return row.Status
+	}
+	return nil
+}
+
+// metric returns the value of a metric, amounts are rounded to the decimals of currency
+func (row StatisticsRow) metric(name string, currency string) interface{} {
+	exponent := utils.CurrencyExponent(currency)
+	switch name {
+	case "count":
+		return row.Count
+	case "sum_quantity":
+		return row.SumQuantity
+	case "avg_quantity":
+		return row.AvgQuantity.Round(2)
+	case "sum_stock_value":
+		return row.SumStockValue.Round(exponent)
+	case "avg_price":
+		return row.AvgPrice.Round(exponent)
+	case "min_price":
+		return row.MinPrice.Round(exponent)
+	case "max_price":
+		return row.MaxPrice.Round(exponent)
+	}
+	return nil
+}
+
+// metricFloat returns the value of a metric for a chart
+func (row StatisticsRow) metricFloat(name string, currency string) float64 {
+	switch value := row.metric(name, currency).(type) {
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case utils.Decimal:
+		return value.Float64()
+	}
+	return 0
+}
+
+// label joins the values of the dimensions of the row
+func (row StatisticsRow) label(dimensions []string) string {
+	values := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		value := "none"
+		if row.dimension(dimension) != nil {
+			value = *row.dimension(dimension)
+		}
+		values = append(values, value)
+	}
+	return strings.Join(values, " / ")
+}
+
+/*
+replyStatisticsChart replies the statistics as a chart when the query has format=png or format=svg.
+  - chart: bar (by default) or pie
+  - It returns false when the statistics must be replied as json
+*/
+func replyStatisticsChart(c *gin.Context, title string, labels []string, values []float64) bool {
+	format := c.Query("format")
+	if format == "" || format == "json" {
+		return false
+	}
+
+	if !charts.IsFormat(format) {
+		apierrors.Reply(c, apierrors.InvalidParam("format", "format must be json, png or svg"))
+		return true
+	}
+
+	var buf bytes.Buffer
+	chart := charts.Chart{Title: title, Kind: c.DefaultQuery("chart", charts.Bar), Labels: labels, Values: values}
+	if err := charts.Render(chart, format, &buf); err != nil {
+		apierrors.Reply(c, apierrors.Internal(err, "have error when render chart"))
+		return true
+	}
+
+	c.Data(http.StatusOK, charts.ContentType(format), buf.Bytes())
+	return true
+}
+
+/*
+drawStatisticsCharts draws a pie chart of the products per dimension of the charts query param in the pdf, 3 charts per row.
+It replies an error and returns false if the charts can not be drawn.
+*/
+func (h *ProductHandler) drawStatisticsCharts(c *gin.Context, pdf *gofpdf.Fpdf) bool {
+	if c.Query("charts") == "" {
+		return true
+	}
+
+	dimensions, err := parseList(c.Query("charts"), statisticsDimensions, nil)
+	if err != nil {
+		apierrors.Reply(c, apierrors.InvalidParam("charts", fmt.Sprintf("invalid charts: %v", err)))
+		return false
+	}
+
+	// size in mm of the 800x500 charts
+	const chartWidth, chartHeight, margin = 130.0, 81.25, 5.0
+	x, y := pdf.GetXY()
+	for i, dimension := range dimensions {
+		rows, _, err := h.runStatistics(c, statisticsRequest{Dimensions: []string{dimension}, Metrics: []string{"count"}})
+		if err != nil {
+			apierrors.Reply(c, err)
+			return false
+		}
+
+		chart := charts.Chart{Title: "Products per " + dimension, Kind: charts.Pie}
+		for _, row := range rows {
+			chart.Labels = append(chart.Labels, row.label([]string{dimension}))
+			chart.Values = append(chart.Values, float64(row.Count))
+		}
+
+		var buf bytes.Buffer
+		if err = charts.Render(chart, charts.PNG, &buf); err != nil {
+			apierrors.Reply(c, apierrors.Internal(err, "have error when render chart"))
+			return false
+		}
+
+		name := "chart-" + dimension
+		options := gofpdf.ImageOptions{ImageType: "PNG"}
+		pdf.RegisterImageOptionsReader(name, options, &buf)
+		pdf.ImageOptions(name,
+			x+float64(i%3)*(chartWidth+margin), y+float64(i/3)*(chartHeight+margin),
+			chartWidth, chartHeight, false, options, 0, "",
+		)
+	}
+
+	chartRows := (len(dimensions) + 2) / 3
+	pdf.SetY(y + float64(chartRows)*(chartHeight+margin))
+	return true
+}
+
+// @Summary      Statistics of products
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Group the products by dimensions and compute metrics per group
+// @Description  Dimensions: category, category_id, supplier, stock_city, status
+// @Description  Metrics: count, sum_quantity, avg_quantity, sum_stock_value, avg_price, min_price, max_price
+// @Description  Statistics are pre-aggregated and refreshed on every product write, computed_at is the time they were last computed
+// @Param        group_by  		query  string  false   "Comma separated dimensions (category by default)"
+// @Param        metrics  		query  string  false   "Comma separated metrics (count by default)"
+// @Param        sort  			query  string  false   "Comma separated dimensions or metrics, prefixed by - for descending order (e.g., -count)"
+// @Param        field    		query  string  false   "Field to filter by (e.g., supplier, category)"
+// @Param        values   		query  array   false   "Values of field"
+// @Param        include_descendants  query  bool  false  "With field=category, match the subcategories too"
+// @Param        tags  		query  array   false   "Names of tags, the products have every tag"
+// @Param        collections  	query  array   false   "Names of collections, the products are in every collection"
+// @Param        currency  		query  string  false   "Currency of the amounts, base currency by default"
+// @Param        page  			query  int     false   "Page number, starts at 1"
+// @Param        perPage  		query  int     false   "Number of groups per page"
+// @Param        format  		query  string  false   "json (by default), png or svg"
+// @Param        chart  		query  string  false   "Chart of png and svg: bar (by default) or pie"
+// @Param        chart_metric  	query  string  false   "Metric drawn in the chart, the first metric by default"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /api/statistics [get]
+func (h *ProductHandler) GetStatistics(c *gin.Context) {
+	var err error
+	req := statisticsRequest{
+		Currency: strings.ToUpper(c.DefaultQuery("currency", utils.BaseCurrency())),
+		Page:     1,
+		PerPage:  50,
+	}
+
+	if req.Dimensions, err = parseGroupBy(c.Query("group_by"), "category"); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	if req.Metrics, err = parseList(c.Query("metrics"), statisticsMetrics, []string{"count"}); err != nil {
+		apierrors.Reply(c, apierrors.InvalidParam("metrics", fmt.Sprintf("invalid metrics: %v", err)))
+		return
+	}
+	if c.Query("sort") != "" {
+		req.Sort = strings.Split(c.Query("sort"), ",")
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		req.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("perPage")); err == nil && perPage > 0 {
+		req.PerPage = perPage
+	}
+
+	rows, total, err := h.runStatistics(c, req)
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	computedAt := statisticsComputedAt(c, rows)
+
+	chartMetric := c.DefaultQuery("chart_metric", req.Metrics[0])
+	if !req.has(chartMetric) {
+		apierrors.Reply(c, apierrors.InvalidParam("chart_metric", "chart_metric must be a selected metric"))
+		return
+	}
+	labels := make([]string, 0, len(rows))
+	values := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		labels = append(labels, row.label(req.Dimensions))
+		values = append(values, row.metricFloat(chartMetric, req.Currency))
+	}
+	title := fmt.Sprintf("%v per %v", chartMetric, strings.Join(req.Dimensions, ", "))
+	if replyStatisticsChart(c, title, labels, values) {
+		return
+	}
+
+	rsp := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		item := gin.H{}
+		for _, dimension := range req.Dimensions {
+			item[dimension] = row.dimension(dimension)
+		}
+		for _, metric := range req.Metrics {
+			item[metric] = row.metric(metric, req.Currency)
+		}
+		rsp = append(rsp, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by":    req.Dimensions,
+		"metrics":     req.Metrics,
+		"currency":    req.Currency,
+		"page":        req.Page,
+		"perPage":     req.PerPage,
+		"total":       total,
+		"computed_at": computedAt,
+		"statistics":  rsp,
+	})
+}
+
+// @Summary      Statistics inventory valuation
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Quantity, stock value (price x quantity) and price statistics of the products per group
+// @Description  computed_at is the time the statistics were last computed
+// @Param        group_by  		query  string  false   "Comma separated dimensions: category, supplier, stock_city, status (category by default)"
+// @Param        field    		query  string  false   "Field to filter by (e.g., supplier, category)"
+// @Param        values   		query  array   false   "Values of field"
+// @Param        include_descendants  query  bool  false  "With field=category, match the subcategories too"
+// @Param        tags  		query  array   false   "Names of tags, the products have every tag"
+// @Param        collections  	query  array   false   "Names of collections, the products are in every collection"
+// @Param        currency  		query  string  false   "Currency of the amounts, base currency by default"
+// @Param        format  		query  string  false   "json (by default), png or svg, the chart shows the stock value"
+// @Param        chart  		query  string  false   "Chart of png and svg: bar (by default) or pie"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /api/statistics/valuation [get]
+func (h *ProductHandler) StatisticsValuation(c *gin.Context) {
+	dimensions, err := parseGroupBy(c.Query("group_by"), "category")
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	req := statisticsRequest{
+		Dimensions: dimensions,
+		Metrics:    []string{"count", "sum_quantity", "sum_stock_value", "avg_price", "min_price", "max_price"},
+		Currency:   strings.ToUpper(c.DefaultQuery("currency", utils.BaseCurrency())),
+	}
+	rows, _, err := h.runStatistics(c, req)
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	computedAt := statisticsComputedAt(c, rows)
+
+	labels := make([]string, 0, len(rows))
+	values := make([]float64, 0, len(rows))
+	for _, row := range rows {
+		labels = append(labels, row.label(dimensions))
+		values = append(values, row.metricFloat("sum_stock_value", req.Currency))
+	}
+	title := fmt.Sprintf("Stock value (%v) per %v", req.Currency, strings.Join(dimensions, ", "))
+	if replyStatisticsChart(c, title, labels, values) {
+		return
+	}
+
+	rsp := make([]gin.H, 0, len(rows))
+	for _, row := range rows {
+		item := gin.H{
+			"total_products":    row.metric("count", req.Currency),
+			"total_quantity":    row.metric("sum_quantity", req.Currency),
+			"total_stock_value": row.metric("sum_stock_value", req.Currency),
+			"avg_price":         row.metric("avg_price", req.Currency),
+			"min_price":         row.metric("min_price", req.Currency),
+			"max_price":         row.metric("max_price", req.Currency),
+		}
+		for _, dimension := range dimensions {
+			item[dimension] = row.dimension(dimension)
+		}
+		rsp = append(rsp, item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"group_by":    dimensions,
+		"currency":    req.Currency,
+		"computed_at": computedAt,
+		"statistics":  rsp,
+	})
+}
+
+// statisticsIntervals are the accepted values of interval and their default range
+var statisticsIntervals = map[string]int{
+	"day":   30,
+	"week":  12,
+	"month": 12,
+}
+
+// maxStatisticsBuckets protects the api from a huge range with a small interval
+const maxStatisticsBuckets = 1000
+
+func truncateToInterval(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case "week":
+		// weeks start on monday like date_trunc of postgres
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+func addInterval(t time.Time, interval string, n int) time.Time {
+	switch interval {
+	case "week":
+		return t.AddDate(0, 0, 7*n)
+	case "month":
+		return t.AddDate(0, n, 0)
+	}
+	return t.AddDate(0, 0, n)
+}
+
+/*
+parseStatisticsBuckets parses the period of a statistics over time and returns the start of every interval:
+  - interval: day, week or month
+  - from, to: dates, to is today and from is a default number of intervals before to when missing
+*/
+func parseStatisticsBuckets(c *gin.Context, defaultInterval string) (string, time.Time, time.Time, []time.Time, *apierrors.Error) {
+	interval := c.DefaultQuery("interval", defaultInterval)
+	defaultBuckets, ok := statisticsIntervals[interval]
+	if !ok {
+		return "", time.Time{}, time.Time{}, nil, apierrors.InvalidParam("interval", "interval must be day, week or month")
+	}
+
+	to := time.Now()
+	if c.Query("to") != "" {
+		var err error
+		if to, err = parseDate(c.Query("to")); err != nil {
+			return "", time.Time{}, time.Time{}, nil, apierrors.InvalidParam("to", "to must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	to = truncateToInterval(to, interval)
+
+	from := addInterval(to, interval, -(defaultBuckets - 1))
+	if c.Query("from") != "" {
+		var err error
+		if from, err = parseDate(c.Query("from")); err != nil {
+			return "", time.Time{}, time.Time{}, nil, apierrors.InvalidParam("from", "from must be RFC3339 or YYYY-MM-DD")
+		}
+	}
+	from = truncateToInterval(from, interval)
+
+	if from.After(to) {
+		return "", time.Time{}, time.Time{}, nil, apierrors.InvalidParam("from", "from must be before to")
+	}
+
+	buckets := make([]time.Time, 0)
+	for bucket := from; !bucket.After(to); bucket = addInterval(bucket, interval, 1) {
+		if len(buckets) == maxStatisticsBuckets {
+			return "", time.Time{}, time.Time{}, nil, apierrors.InvalidParam("from", fmt.Sprintf("too many intervals between from and to, max is %v", maxStatisticsBuckets))
+		}
+		buckets = append(buckets, bucket)
+	}
+	return interval, from, to, buckets, nil
+}
+
+// @Summary      Statistics products added over time
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Number of products added per interval and cumulative total of products at the end of each interval
+// @Description  Intervals without products have a count of 0
+// @Param        interval  		query  string  false   "day, week or month (day by default)"
+// @Param        from    		query  string  false   "Start date (YYYY-MM-DD or RFC3339)"
+// @Param        to    			query  string  false   "End date (YYYY-MM-DD or RFC3339), today by default"
+// @Param        split_by  		query  string  false   "One series per category, supplier, stock_city or status"
+// @Param        field    		query  string  false   "Field to filter by (e.g., supplier, category)"
+// @Param        values   		query  array   false   "Values of field"
+// @Param        format  		query  string  false   "json (by default), png or svg, the chart shows the count of all series"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /api/statistics/products-added [get]
+func (h *ProductHandler) StatisticsProductsAdded(c *gin.Context) {
+	interval, from, to, buckets, err := parseStatisticsBuckets(c, "day")
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	splitColumn := "NULL"
+	splitBy := c.Query("split_by")
+	if splitBy != "" {
+		var ok bool
+		if splitColumn, ok = statisticsDimensions[splitBy]; !ok {
+			apierrors.Reply(c, apierrors.InvalidParam("split_by", fmt.Sprintf("can not split by %v", splitBy)))
+			return
+		}
+	}
+
+	/*
+		Products added before from are counted in a bucket at from - 1 interval:
+		they are not returned but they are the start of the cumulative totals
+	*/
+	end := addInterval(to, interval, 1)
+	bucketExpr := "GREATEST(date_trunc(?, product.added_date::TIMESTAMPTZ AT TIME ZONE 'UTC'), ?)"
+	query := h.db.Model(&Product{}).
+		Join("LEFT JOIN categories AS category ON category.id = product.category_id").
+		Join("LEFT JOIN suppliers AS supplier ON supplier.id = product.supplier_id").
+		ColumnExpr(fmt.Sprintf("%v AS name", splitColumn)).
+		ColumnExpr(bucketExpr+" AS bucket", interval, addInterval(from, interval, -1)).
+		ColumnExpr("COUNT(*) AS total_products").
+		Where("product.added_date < ?", end).
+		GroupExpr("1, 2").
+		OrderExpr("1, 2")
+
+	if err := applyProductFilters(c, query); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	rows := make([]ProductsAddedRow, 0)
+	if err := query.Select(&rows); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when statistic products added"))
+		return
+	}
+
+	// counts per series then per bucket, series are sorted by name
+	names := make([]*string, 0)
+	counts := make(map[string]map[time.Time]int)
+	for _, row := range rows {
+		key := ""
+		if row.Name != nil {
+			key = *row.Name
+		}
+		if _, exists := counts[key]; !exists {
+			counts[key] = make(map[time.Time]int)
+			names = append(names, row.Name)
+		}
+		counts[key][row.Bucket.UTC()] += row.TotalProducts
+	}
+	if len(names) == 0 && splitBy == "" {
+		names = append(names, nil)
+		counts[""] = make(map[time.Time]int)
+	}
+
+	series := make([]gin.H, 0, len(names))
+	for _, name := range names {
+		key := ""
+		if name != nil {
+			key = *name
+		}
+
+		cumulative := counts[key][addInterval(from, interval, -1)]
+		points := make([]ProductsAddedBucket, 0, len(buckets))
+		for _, bucket := range buckets {
+			cumulative += counts[key][bucket]
+			points = append(points, ProductsAddedBucket{
+				Start:      bucket.Format(time.DateOnly),
+				Count:      counts[key][bucket],
+				Cumulative: cumulative,
+			})
+		}
+
+		series = append(series, gin.H{
+			"name":    name,
+			"buckets": points,
+		})
+	}
+
+	labels := make([]string, 0, len(buckets))
+	values := make([]float64, len(buckets))
+	for i, bucket := range buckets {
+		labels = append(labels, bucket.Format(time.DateOnly))
+		for _, seriesCounts := range counts {
+			values[i] += float64(seriesCounts[bucket])
+		}
+	}
+	if replyStatisticsChart(c, fmt.Sprintf("Products added per %v", interval), labels, values) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"interval": interval,
+		"from":     from.Format(time.DateOnly),
+		"to":       to.Format(time.DateOnly),
+		"split_by": splitBy,
+		"series":   series,
+	})
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/utils"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// lockProducts selects the products for update, in the order of their ids so concurrent movements can not deadlock
+func lockProducts(tx *pg.Tx, ids []string) (map[string]*Product, error) {
+	products := make([]Product, 0, len(ids))
+	err := tx.Model(&products).Where("id IN (?)", pg.In(ids)).Order("id ASC").For("UPDATE").Select()
+	if err != nil {
+		return nil, apierrors.FromDB(err, "have error when get products")
+	}
+
+	locked := make(map[string]*Product, len(products))
+	for i := range products {
+		locked[products[i].ID] = &products[i]
+	}
+	for _, id := range ids {
+		if locked[id] == nil {
+			return nil, apierrors.NotFound("product not found").With("product_id", id)
+		}
+	}
+	return locked, nil
+}
+
+// moveStock changes the stock of a locked product by movement.Quantity, records the movement and the new version of the product
+func moveStock(tx orm.DB, c *gin.Context, product *Product, movement *StockMovement) error {
+	before := *product
+	product.Quantity += movement.Quantity
+	product.Version++
+	product.UpdatedAt = time.Now()
+
+	// Set writes a quantity of 0, Update would write NULL for the zero value
+	_, err := tx.Model(product).
+		Set("quantity = ?", product.Quantity).
+		Set("version = ?", product.Version).
+		Set("updated_at = ?", product.UpdatedAt).
+		WherePK().
+		Update()
+	if err != nil {
+		return apierrors.FromDB(err, "have error when update stock")
+	}
+
+	movement.ProductID = product.ID
+	movement.CreatedBy = currentUserEmail(c)
+	movement.CreatedAt = product.UpdatedAt
+	if _, err := tx.Model(movement).Insert(); err != nil {
+		return apierrors.FromDB(err, "have error when record stock movement")
+	}
+
+	action := "stock_" + movement.Kind
+	err = handlers.RecordAudit(tx, c, models.AuditEvent{Action: action, Entity: "product", EntityID: product.ID}, before, product)
+	if err != nil {
+		return apierrors.FromDB(err, "have error when record audit")
+	}
+	if err := recordProductVersion(tx, c, action, product); err != nil {
+		return apierrors.FromDB(err, "have error when record product version")
+	}
+	return nil
+}
+
+/*
+stockChanges returns the signed change of stock of every product moved by the request:
+the product itself, or every component of the sold bundle.
+*/
+func stockChanges(db orm.DB, req StockMovementRequest) (map[string]int, *int64, error) {
+	if (req.ProductID == "") == (req.BundleID == 0) {
+		return nil, nil, apierrors.InvalidField("product_id", "either product_id or bundle_id is required")
+	}
+	if req.Kind != "adjustment" && req.Quantity <= 0 {
+		return nil, nil, apierrors.InvalidField("quantity", "quantity of a receipt or a sale must be positive")
+	}
+	if req.Lot != nil && req.Lot.ExpiryDate != "" && (req.Kind == "sale" || req.Quantity < 0) {
+		return nil, nil, apierrors.InvalidField("lot.expiry_date", "the expiry date is given when the lot is received")
+	}
+
+	quantity := req.Quantity
+	if req.Kind == "sale" {
+		quantity = -quantity
+	}
+
+	if req.ProductID != "" {
+		return map[string]int{req.ProductID: quantity}, nil, nil
+	}
+
+	if req.Kind != "sale" {
+		return nil, nil, apierrors.InvalidField("kind", "a bundle has no stock of its own, it can only be sold")
+	}
+	if req.Lot != nil {
+		return nil, nil, apierrors.InvalidField("lot", "the components of a bundle are taken from the lots expiring first")
+	}
+
+	bundle, err := findBundle(db, fmt.Sprint(req.BundleID))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changes := make(map[string]int, len(bundle.Components))
+	for _, component := range bundle.Components {
+		changes[component.ProductID] = quantity * component.Quantity
+	}
+	return changes, &bundle.ID, nil
+}
+
+/*
+receiptMovement returns the movement of the request without its product and quantity,
+with the purchase order of a receipt completed from the price list of the supplier.
+*/
+func (h *ProductHandler) receiptMovement(req StockMovementRequest) (StockMovement, error) {
+	movement := StockMovement{Kind: req.Kind, Note: req.Note}
+	receipt := req.Receipt
+	if receipt == nil {
+		return movement, nil
+	}
+	if req.Kind != "receipt" || req.ProductID == "" {
+		return movement, apierrors.InvalidField("receipt", "a purchase order can only be given with a receipt of a product")
+	}
+	if receipt.OrderedAt.After(time.Now()) {
+		return movement, apierrors.InvalidField("receipt.ordered_at", "ordered_at can not be in the future")
+	}
+
+	offer := &ProductSupplier{}
+	err := h.db.Model(offer).
+		Where("product_id = ?", req.ProductID).
+		Where("supplier_id = ?", receipt.SupplierID).
+		Select()
+	if err == pg.ErrNoRows {
+		offer = nil
+	} else if err != nil {
+		return movement, apierrors.FromDB(err, "have error when get supplier of product")
+	}
+
+	movement.SupplierID = receipt.SupplierID
+	movement.OrderedAt = &receipt.OrderedAt
+	movement.ExpectedAt = receipt.ExpectedAt
+	if movement.ExpectedAt == nil && offer != nil {
+		expectedAt := receipt.OrderedAt.AddDate(0, 0, offer.LeadTimeDays)
+		movement.ExpectedAt = &expectedAt
+	}
+	movement.OrderedQuantity = receipt.OrderedQuantity
+	if movement.OrderedQuantity == 0 {
+		movement.OrderedQuantity = req.Quantity
+	}
+
+	movement.Currency = strings.ToUpper(receipt.Currency)
+	if movement.Currency == "" && offer != nil {
+		movement.Currency = offer.Currency
+	}
+	if movement.Currency == "" {
+		movement.Currency = h.defaultCurrency(receipt.SupplierID)
+	}
+	if !utils.IsCurrency(movement.Currency) {
+		return movement, apierrors.InvalidField("receipt.currency", "invalid currency")
+	}
+
+	// the cost of the price list is converted to the currency of the receipt, it is unknown without exchange rate
+	if offer != nil {
+		rates, err := loadExchangeRates(h.db, time.Now())
+		if err != nil {
+			return movement, apierrors.FromDB(err, "have error when get exchange rates")
+		}
+		if listUnitCost, err := rates.convert(offer.UnitCost, offer.Currency, movement.Currency); err == nil {
+			movement.ListUnitCost = &listUnitCost
+		}
+	}
+	movement.UnitCost = receipt.UnitCost
+	return movement, nil
+}
+
+// @Summary      Move stock
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Receive, sell or adjust the stock (quantity) of a product, or sell a bundle which takes the stock of every component
+// @Description  The products are changed together or not at all, reply 409 insufficient_stock with the shortages if a stock would be negative
+// @Description  A receipt can give the purchase order it delivers (receipt), see /api/statistics/suppliers/:id/scorecard
+// @Description  An incoming movement with lot adds to the lot, an outgoing movement takes from the lot, or from the lots not expired which expire first (FEFO)
+// @Param        request  body  StockMovementRequest  true  "Stock movement"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /stock/movements [post]
+func (h *ProductHandler) CreateStockMovement(c *gin.Context) {
+	var req StockMovementRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	changes, bundleID, err := stockChanges(h.db, req)
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	base, err := h.receiptMovement(req)
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	ids := make([]string, 0, len(changes))
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	movements := make([]StockMovement, 0, len(ids))
+	products := make([]*Product, 0, len(ids))
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		locked, err := lockProducts(tx, ids)
+		if err != nil {
+			return err
+		}
+
+		shortages := make([]gin.H, 0)
+		for _, id := range ids {
+			if changes[id] < 0 && locked[id].Quantity+changes[id] < 0 {
+				shortages = append(shortages, gin.H{"product_id": id, "stock": locked[id].Quantity, "required": -changes[id]})
+			}
+		}
+		if len(shortages) > 0 {
+			return apierrors.New(http.StatusConflict, apierrors.CodeInsufficientStock, "insufficient stock").With("shortages", shortages)
+		}
+
+		for _, id := range ids {
+			movement := base
+			movement.BundleID = bundleID
+			movement.Quantity = changes[id]
+			if err := moveStock(tx, c, locked[id], &movement); err != nil {
+				return err
+			}
+			if err := allocateLots(tx, locked[id], &movement, req); err != nil {
+				return err
+			}
+			movements = append(movements, movement)
+			products = append(products, locked[id])
+		}
+		return nil
+	})
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":       "move stock successfully",
+		"movements": movements,
+		"products":  products,
+	})
+}
+
+// @Summary      Get stock movements of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The movements of the stock of the product, the latest first
+// @Param        id  path  int  true  "Product ID"
+// @Param        kind  query  string  false  "receipt, sale or adjustment"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/stock-movements [get]
+func (h *ProductHandler) GetProductStockMovements(c *gin.Context) {
+	movements := make([]StockMovement, 0)
+	query := h.db.Model(&movements).Where("product_id = ?", c.Param("id"))
+	if kind := c.Query("kind"); kind != "" {
+		query.Where("kind = ?", kind)
+	}
+
+	if err := query.Relation("Allocations").Order("created_at DESC", "id DESC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get stock movements"))
+		return
+	}
+
+	if err := fillLotNumbers(h.db, movements); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get lots"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"movements": movements,
+	})
+}
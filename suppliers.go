@@ -0,0 +1,376 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/utils"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// @Summary      Get suppliers of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The offers of the suppliers of the product, the preferred supplier first
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers [get]
+func (h *ProductHandler) GetProductSuppliers(c *gin.Context) {
+	offers := make([]ProductSupplier, 0)
+	err := h.db.Model(&offers).
+		Relation("Supplier").
+		Where("product_supplier.product_id = ?", c.Param("id")).
+		Order("product_supplier.is_preferred DESC", "product_supplier.unit_cost ASC", "product_supplier.id ASC").
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get suppliers of product"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"suppliers": offers,
+	})
+}
+
+// @Summary      Add supplier to product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Without currency, the cost is in the currency of the supplier. The first supplier of a product is its preferred supplier
+// @Description  Reply 409 if the supplier already supplies the product
+// @Param        request  body  ProductSupplierRequest  true  "Offer of the supplier"
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers [post]
+func (h *ProductHandler) AddProductSupplier(c *gin.Context) {
+	var req ProductSupplierRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if req.SupplierID == "" {
+		apierrors.Reply(c, apierrors.Validation(apierrors.FieldError{Field: "supplier_id", Code: apierrors.FieldRequired, Message: "supplier_id is required"}))
+		return
+	}
+
+	product := &Product{ID: c.Param("id")}
+	if err := h.db.Model(product).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	offer := &ProductSupplier{ProductID: product.ID, SupplierID: req.SupplierID, CreatedAt: time.Now()}
+	if err := h.applySupplierRequest(offer, req); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// the first supplier of a product is its preferred supplier
+		hasPreferred, err := tx.Model((*ProductSupplier)(nil)).Where("product_id = ?", offer.ProductID).Where("is_preferred").Exists()
+		if err != nil {
+			return err
+		}
+		offer.IsPreferred = !hasPreferred
+
+		if _, err = tx.Model(offer).Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "product_supplier", EntityID: fmt.Sprint(offer.ID)}, nil, offer)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when add supplier to product"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":      "add supplier to product successfully",
+		"supplier": offer,
+	})
+}
+
+// @Summary      Update supplier of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Replace the sku, the cost, the minimum order quantity and the lead time of the supplier
+// @Param        request  body  ProductSupplierRequest  true  "Offer of the supplier"
+// @Param        id  path  int  true  "Product ID"
+// @Param        supplier_id  path  int  true  "Supplier ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers/:supplier_id [put]
+func (h *ProductHandler) UpdateProductSupplier(c *gin.Context) {
+	var req ProductSupplierRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	offer, err := findProductSupplier(h.db, c.Param("id"), c.Param("supplier_id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+	before := *offer
+
+	if err := h.applySupplierRequest(offer, req); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Model(offer).
+			Column("sku", "unit_cost", "currency", "min_order_quantity", "lead_time_days", "updated_at").
+			WherePK().
+			Update()
+		if err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "update", Entity: "product_supplier", EntityID: fmt.Sprint(offer.ID)}, before, offer)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when update supplier of product"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":      "update supplier of product successfully",
+		"supplier": offer,
+	})
+}
+
+// @Summary      Make supplier the preferred supplier of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Product ID"
+// @Param        supplier_id  path  int  true  "Supplier ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers/:supplier_id/preferred [post]
+func (h *ProductHandler) SetPreferredProductSupplier(c *gin.Context) {
+	offer, err := findProductSupplier(h.db, c.Param("id"), c.Param("supplier_id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		_, err := tx.Model((*ProductSupplier)(nil)).
+			Set("is_preferred = FALSE").
+			Where("product_id = ?", offer.ProductID).
+			Where("is_preferred").
+			Update()
+		if err != nil {
+			return err
+		}
+
+		offer.IsPreferred = true
+		if _, err = tx.Model(offer).Column("is_preferred").WherePK().Update(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "set_preferred", Entity: "product_supplier", EntityID: fmt.Sprint(offer.ID)}, nil, offer)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when set preferred supplier"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":      "set preferred supplier successfully",
+		"supplier": offer,
+	})
+}
+
+// @Summary      Remove supplier from product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  When the preferred supplier is removed, the cheapest other supplier becomes preferred
+// @Param        id  path  int  true  "Product ID"
+// @Param        supplier_id  path  int  true  "Supplier ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers/:supplier_id [delete]
+func (h *ProductHandler) DeleteProductSupplier(c *gin.Context) {
+	offer, err := findProductSupplier(h.db, c.Param("id"), c.Param("supplier_id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(offer).WherePK().Delete(); err != nil {
+			return err
+		}
+
+		if offer.IsPreferred {
+			_, err := tx.Exec(`
+				UPDATE product_suppliers SET is_preferred = TRUE
+				WHERE id = (
+					SELECT id FROM product_suppliers WHERE product_id = ?
+					ORDER BY unit_cost ASC, id ASC LIMIT 1
+				)`, offer.ProductID)
+			if err != nil {
+				return err
+			}
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "product_supplier", EntityID: fmt.Sprint(offer.ID)}, offer, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when remove supplier from product"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "remove supplier from product successfully",
+	})
+}
+
+// @Summary      Recommend supplier of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Rank the suppliers which accept an order of quantity units (minimum order quantity), by total cost or by lead time
+// @Description  The costs are converted to currency to be compared, a tie is broken by the other criteria then by the preferred supplier
+// @Param        id  path  int  true  "Product ID"
+// @Param        quantity  query  int  true  "Quantity to order"
+// @Param        by  query  string  false  "cost (by default) or lead_time"
+// @Param        currency  query  string  false  "Currency of the total costs, the base currency by default"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/suppliers/recommendation [get]
+func (h *ProductHandler) RecommendProductSupplier(c *gin.Context) {
+	quantity, err := strconv.Atoi(c.Query("quantity"))
+	if err != nil || quantity <= 0 {
+		apierrors.Reply(c, apierrors.InvalidParam("quantity", "quantity must be a positive integer"))
+		return
+	}
+
+	by := c.DefaultQuery("by", "cost")
+	if by != "cost" && by != "lead_time" {
+		apierrors.Reply(c, apierrors.InvalidParam("by", "by must be cost or lead_time"))
+		return
+	}
+
+	currency := strings.ToUpper(c.DefaultQuery("currency", utils.BaseCurrency()))
+	if !utils.IsCurrency(currency) {
+		apierrors.Reply(c, apierrors.InvalidParam("currency", "invalid currency"))
+		return
+	}
+
+	offers := make([]ProductSupplier, 0)
+	err = h.db.Model(&offers).
+		Relation("Supplier").
+		Where("product_supplier.product_id = ?", c.Param("id")).
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get suppliers of product"))
+		return
+	}
+
+	if len(offers) == 0 {
+		apierrors.Reply(c, apierrors.NotFound("product has no supplier"))
+		return
+	}
+
+	rates, err := loadExchangeRates(h.db, time.Now())
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get exchange rates"))
+		return
+	}
+
+	ranked := make([]SupplierOffer, 0, len(offers))
+	excluded := make([]ProductSupplier, 0)
+	for _, offer := range offers {
+		if offer.MinOrderQuantity > quantity {
+			excluded = append(excluded, offer)
+			continue
+		}
+
+		totalCost, err := rates.convert(offer.UnitCost.MulInt(int64(quantity)), offer.Currency, currency)
+		if err != nil {
+			apierrors.Reply(c, apierrors.InvalidParam("currency", err.Error()))
+			return
+		}
+		ranked = append(ranked, SupplierOffer{ProductSupplier: offer, TotalCost: totalCost})
+	}
+
+	if len(ranked) == 0 {
+		apierrors.Reply(c, apierrors.InvalidParam("quantity", "quantity is below the minimum order quantity of every supplier").With("excluded", excluded))
+		return
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].betterThan(ranked[j], by)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"quantity":    quantity,
+		"by":          by,
+		"currency":    currency,
+		"recommended": ranked[0],
+		"ranking":     ranked,
+		"excluded":    excluded,
+	})
+}
+
+// betterThan compares the offers by cost then lead time, or by lead time then cost, then prefers the preferred supplier
+func (o SupplierOffer) betterThan(other SupplierOffer, by string) bool {
+	if by == "lead_time" && o.LeadTimeDays != other.LeadTimeDays {
+		return o.LeadTimeDays < other.LeadTimeDays
+	}
+	if cmp := o.TotalCost.Cmp(other.TotalCost); cmp != 0 {
+		return cmp < 0
+	}
+	if o.LeadTimeDays != other.LeadTimeDays {
+		return o.LeadTimeDays < other.LeadTimeDays
+	}
+	return o.IsPreferred && !other.IsPreferred
+}
+
+// applySupplierRequest sets the fields of an offer from a request, the currency defaults to the currency of the supplier
+func (h *ProductHandler) applySupplierRequest(offer *ProductSupplier, req ProductSupplierRequest) *apierrors.Error {
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = h.defaultCurrency(offer.SupplierID)
+	}
+	if !utils.IsCurrency(currency) {
+		return apierrors.InvalidField("currency", "invalid currency")
+	}
+
+	offer.SKU = req.SKU
+	offer.UnitCost = req.UnitCost
+	offer.Currency = currency
+	offer.MinOrderQuantity = req.MinOrderQuantity
+	if offer.MinOrderQuantity == 0 {
+		offer.MinOrderQuantity = 1
+	}
+	offer.LeadTimeDays = req.LeadTimeDays
+	offer.UpdatedAt = time.Now()
+	return nil
+}
+
+func findProductSupplier(db orm.DB, productID string, supplierID string) (*ProductSupplier, error) {
+	offer := &ProductSupplier{}
+	err := db.Model(offer).
+		Relation("Supplier").
+		Where("product_supplier.product_id = ?", productID).
+		Where("product_supplier.supplier_id = ?", supplierID).
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return nil, apierrors.NotFound("supplier does not supply the product")
+		}
+
+		return nil, apierrors.FromDB(err, "have error when get supplier of product")
+	}
+	return offer, nil
+}
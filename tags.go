@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"net/http"
+	"strings"
+)
+
+func init() {
+	// the join tables of the many to many relations of Product
+	orm.RegisterTable((*ProductTag)(nil))
+	orm.RegisterTable((*ProductCollection)(nil))
+}
+
+// orderTags sorts the tags of a product by name, used with Relation("Tags", orderTags)
+func orderTags(q *orm.Query) (*orm.Query, error) {
+	return q.Order("tag.name ASC"), nil
+}
+
+// orderCollections sorts the collections of a product by name, used with Relation("Collections", orderCollections)
+func orderCollections(q *orm.Query) (*orm.Query, error) {
+	return q.Order("collection.name ASC"), nil
+}
+
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+/*
+applyTagFilters adds the filters tags and collections (names) to a query on products,
+a product matches when it has every tag and is in every collection.
+*/
+func applyTagFilters(c *gin.Context, query *orm.Query) {
+	for _, name := range c.QueryArray("tags") {
+		query.Where(`EXISTS (
+			SELECT 1 FROM product_tags pt JOIN tags t ON t.id = pt.tag_id
+			WHERE pt.product_id = product.id::TEXT AND t.name = ?)`, normalizeTagName(name))
+	}
+	for _, name := range c.QueryArray("collections") {
+		query.Where(`EXISTS (
+			SELECT 1 FROM product_collections pc JOIN collections col ON col.id = pc.collection_id
+			WHERE pc.product_id = product.id::TEXT AND col.name = ?)`, name)
+	}
+}
+
+// checkProductIDs replies a validation error listing the products which do not exist or are in the trash
+func checkProductIDs(db orm.DB, ids []string) *apierrors.Error {
+	found := make([]string, 0, len(ids))
+	err := db.Model((*Product)(nil)).Column("id").Where("id IN (?)", pg.In(ids)).Select(&found)
+	if err != nil {
+		return apierrors.FromDB(err, "have error when get products")
+	}
+
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+
+	missing := make([]string, 0)
+	for _, id := range ids {
+		if !exists[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return apierrors.InvalidField("product_ids", "products not found: "+strings.Join(missing, ", ")).With("missing", missing)
+	}
+	return nil
+}
+
+// uniqueIDs removes the duplicated ids, keeping the first occurrence
+func uniqueIDs(ids []string) []string {
+	seen := make(map[string]bool, len(ids))
+	unique := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// bindMembershipRequest binds the products of a membership request and checks they exist when adding
+func bindMembershipRequest(c *gin.Context, db orm.DB, adding bool) ([]string, bool) {
+	var req ProductMembershipRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return nil, false
+	}
+
+	ids := uniqueIDs(req.ProductIDs)
+	if adding {
+		if err := checkProductIDs(db, ids); err != nil {
+			apierrors.Reply(c, err)
+			return nil, false
+		}
+	}
+	return ids, true
+}
+
+// @Summary      Get tags
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Every tag with its number of products, the products in the trash are not counted
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /tags [get]
+func (h *ProductHandler) GetTags(c *gin.Context) {
+	tags := make([]Tag, 0)
+	if err := h.db.Model(&tags).Order("name ASC").Select(); err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get tags"))
+		return
+	}
+
+	counts := make([]struct {
+		TagID int64
+		Count int
+	}, 0)
+	err := h.db.Model((*ProductTag)(nil)).
+		ColumnExpr("product_tag.tag_id, count(*) AS count").
+		Join("JOIN products p ON p.id::TEXT = product_tag.product_id AND p.deleted_at IS NULL").
+		Group("product_tag.tag_id").
+		Select(&counts)
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when count products of tags"))
+		return
+	}
+
+	productCounts := make(map[int64]int, len(counts))
+	for _, count := range counts {
+		productCounts[count.TagID] = count.Count
+	}
+	for i := range tags {
+		tags[i].ProductCount = productCounts[tags[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tags": tags,
+	})
+}
+
+// @Summary      Create tag
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The name is lower cased, reply 409 if the tag exists
+// @Param        request  body  TagRequest  true  "Tag"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /tags [post]
+func (h *ProductHandler) CreateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	tag := &Tag{Name: normalizeTagName(req.Name)}
+	if tag.Name == "" {
+		apierrors.Reply(c, apierrors.InvalidField("name", "name is required"))
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(tag).Returning("*").Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "tag", EntityID: fmt.Sprint(tag.ID)}, nil, tag)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "create tag successfully",
+		"tag": tag,
+	})
+}
+
+// @Summary      Delete tag
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  The tag is removed from its products
+// @Param        id  path  int  true  "Tag ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /tags/:id [delete]
+func (h *ProductHandler) DeleteTag(c *gin.Context) {
+	tag, err := findTag(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if _, err := tx.Model(tag).WherePK().Delete(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "tag", EntityID: fmt.Sprint(tag.ID)}, tag, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when delete tag"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "delete tag successfully",
+	})
+}
+
+// @Summary      Tag products
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Add the tag to every product of product_ids, the products already tagged are skipped
+// @Param        request  body  ProductMembershipRequest  true  "Products"
+// @Param        id  path  int  true  "Tag ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /tags/:id/products [post]
+func (h *ProductHandler) AddTagProducts(c *gin.Context) {
+	tag, err := findTag(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	ids, ok := bindMembershipRequest(c, h.db, true)
+	if !ok {
+		return
+	}
+
+	links := make([]ProductTag, 0, len(ids))
+	for _, id := range ids {
+		links = append(links, ProductTag{TagID: tag.ID, ProductID: id})
+	}
+
+	var res orm.Result
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if res, err = tx.Model(&links).OnConflict("DO NOTHING").Insert(); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "add_products", Entity: "tag", EntityID: fmt.Sprint(tag.ID)}, nil, gin.H{"product_ids": ids})
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when tag products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":   "tag products successfully",
+		"added": res.RowsAffected(),
+	})
+}
+
+// @Summary      Untag products
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Remove the tag from every product of product_ids
+// @Param        request  body  ProductMembershipRequest  true  "Products"
+// @Param        id  path  int  true  "Tag ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /tags/:id/products [delete]
+func (h *ProductHandler) RemoveTagProducts(c *gin.Context) {
+	tag, err := findTag(h.db, c.Param("id"))
+	if err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	ids, ok := bindMembershipRequest(c, h.db, false)
+	if !ok {
+		return
+	}
+
+	var res orm.Result
+	err = h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err = tx.Model((*ProductTag)(nil)).
+			Where("tag_id = ?", tag.ID).
+			Where("product_id IN (?)", pg.In(ids)).
+			Delete()
+		if err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "remove_products", Entity: "tag", EntityID: fmt.Sprint(tag.ID)}, gin.H{"product_ids": ids}, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when untag products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg":     "untag products successfully",
+		"removed": res.RowsAffected(),
+	})
+}
+
+func findTag(db orm.DB, id string) (*Tag, error) {
+	tag := &Tag{}
+	if err := db.Model(tag).Where("id = ?", id).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			return nil, apierrors.NotFound("tag not found")
+		}
+
+		return nil, apierrors.FromDB(err, "have error when get tag")
+	}
+	return tag, nil
+}
+
+// purgeProductMemberships removes the purged products from the tags, the collections and the price lists of the suppliers
+func purgeProductMemberships(db orm.DB, ids []string) error {
+	for _, model := range []interface{}{(*ProductTag)(nil), (*ProductCollection)(nil), (*ProductSupplier)(nil)} {
+		if _, err := db.Model(model).Where("product_id IN (?)", pg.In(ids)).Delete(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/cache"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/storage"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// @Summary      Get deleted products
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        perPage  		query  int     false   "Number of products per page"
+// @Param        last_reference query  string  false   "The last reference of previous page"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/trash [get]
+func (h *ProductHandler) GetTrash(c *gin.Context) {
+	var req ProductRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+
+	if req.PerPage <= 0 {
+		req.PerPage = 10
+	}
+
+	products := make([]Product, 0)
+	query := h.db.Model(&products).Deleted()
+
+	if req.LastReference != "" {
+		query.Where("reference < ?", req.LastReference)
+	}
+
+	err := query.Relation("Category").Relation("Supplier").
+		Order("reference DESC").
+		Limit(req.PerPage).
+		Select()
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get deleted products"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"products": products,
+	})
+}
+
+// @Summary      Restore product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Move a deleted product out of the trash
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/restore [post]
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	id := c.Param("id")
+	product := &Product{ID: id}
+	if err := h.db.Model(product).WherePK().Deleted().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found in trash"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get deleted product"))
+		return
+	}
+
+	before := *product
+	currentVersion := product.Version
+	product.DeletedAt = nil
+	product.Version = currentVersion + 1
+	product.UpdatedAt = time.Now()
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err := tx.Model(product).Column("deleted_at", "version", "updated_at").
+			WherePK().Where("version = ?", currentVersion).Deleted().Update()
+		if err != nil {
+			return apierrors.FromDB(err, "have error when restore product")
+		}
+
+		if res.RowsAffected() == 0 {
+			return versionMismatchError(tx, id)
+		}
+
+		err = handlers.RecordAudit(tx, c, models.AuditEvent{Action: "restore", Entity: "product", EntityID: id}, before, product)
+		if err != nil {
+			return apierrors.FromDB(err, "have error when record audit")
+		}
+		if err := recordProductVersion(tx, c, "restore", product); err != nil {
+			return apierrors.FromDB(err, "have error when record product version")
+		}
+		return nil
+	})
+	if err != nil {
+		replyProductError(c, err)
+		return
+	}
+
+	c.Header("ETag", productETag(product))
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "restore product successfully",
+	})
+}
+
+// @Summary      Purge product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Permanently delete a product from the trash, only for admin
+// @Param        id  path  int  true  "Product ID"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/purge [delete]
+func (h *ProductHandler) PurgeProduct(c *gin.Context) {
+	id := c.Param("id")
+	product := &Product{ID: id}
+	if err := h.db.Model(product).WherePK().Deleted().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found in trash"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get deleted product"))
+		return
+	}
+
+	var media []ProductMedia
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err := tx.Model(product).WherePK().Deleted().ForceDelete()
+		if err != nil {
+			return err
+		}
+
+		if res.RowsAffected() == 0 {
+			return apierrors.NotFound("product not found in trash")
+		}
+
+		if media, err = purgeProductRows(tx, []string{id}); err != nil {
+			return err
+		}
+		return handlers.RecordAudit(tx, c, models.AuditEvent{Action: "purge", Entity: "product", EntityID: id}, product, nil)
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when purge product"))
+		return
+	}
+
+	// the files are deleted once the rows are, a failed transaction keeps them
+	for i := range media {
+		h.deleteMediaFiles(&media[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "purge product successfully",
+	})
+}
+
+/*
+startTrashPurger permanently deletes products which stay in the trash longer than the retention.
+  - TRASH_RETENTION_DAYS: number of days a deleted product is kept, 0 or empty disables the purge
+*/
+func startTrashPurger(db *pg.DB, responseCache cache.Cache, mediaStorage storage.Storage) {
+	retentionDays, _ := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if retentionDays <= 0 {
+		return
+	}
+	retention := time.Duration(retentionDays) * 24 * time.Hour
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for ; true; <-ticker.C {
+			var media []ProductMedia
+			ids := make([]string, 0)
+			err := db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+				err := tx.Model((*Product)(nil)).Deleted().Column("id").
+					Where("deleted_at < ?", time.Now().Add(-retention)).
+					For("UPDATE").
+					Select(&ids)
+				if err != nil || len(ids) == 0 {
+					return err
+				}
+
+				if _, err := tx.Model((*Product)(nil)).Deleted().Where("id IN (?)", pg.In(ids)).ForceDelete(); err != nil {
+					return err
+				}
+				media, err = purgeProductRows(tx, ids)
+				return err
+			})
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+
+			if len(ids) > 0 {
+				for i := range media {
+					deleteMediaFiles(mediaStorage, &media[i])
+				}
+
+				fmt.Printf("purged %v products from trash\n", len(ids))
+				invalidateCache(responseCache, cache.Products)
+			}
+		}
+	}()
+}
+
+/*
+purgeProductRows deletes the rows of the other tables which belong to the purged products, in the transaction of the purge.
+The stock movements stay as the history of the stock. It returns the deleted media, whose files are deleted after the commit.
+*/
+func purgeProductRows(tx orm.DB, ids []string) ([]ProductMedia, error) {
+	tables := []interface{}{(*ProductVariant)(nil), (*ProductVersion)(nil), (*ProductPrice)(nil), (*BundleComponent)(nil)}
+	for _, model := range tables {
+		if _, err := tx.Model(model).Where("product_id IN (?)", pg.In(ids)).Delete(); err != nil {
+			return nil, err
+		}
+	}
+
+	// the allocations of the movements reference the lots
+	_, err := tx.Exec(`DELETE FROM stock_lot_allocations WHERE lot_id IN (SELECT id FROM stock_lots WHERE product_id IN (?))`, pg.In(ids))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Model((*StockLot)(nil)).Where("product_id IN (?)", pg.In(ids)).Delete(); err != nil {
+		return nil, err
+	}
+
+	if err := purgeProductMemberships(tx, ids); err != nil {
+		return nil, err
+	}
+	return purgeProductMedia(tx, ids)
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/umahmood/haversine"
+	"manage-products/apierrors"
+	"manage-products/cache"
 	"net/http"
 	"os"
 )
@@ -39,27 +42,26 @@ func getIPLocation(ip string) (haversine.Coord, error) {
 // @Description  Calculate Distance from your location to a city
 // @Param        city   query  string     false   "City"
 // @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
 // @Router       /distance [get]
 func calculateDistance(c *gin.Context) {
 	ip := c.ClientIP()
 	city := c.Query("city")
 
 	if city == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"msg": "Missing city",
-		})
+		apierrors.Reply(c, apierrors.InvalidParam("city", "missing city"))
 		return
 	}
 
 	userLocation, err := getIPLocation(ip)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get IP location"})
+		apierrors.Reply(c, &apierrors.Error{Status: http.StatusBadGateway, Code: apierrors.CodeUpstreamFailed, Detail: "failed to get IP location", Err: err})
 		return
 	}
 
 	cityLocation, exists := cityCoordinates[city]
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "City not found no data yet"})
+		apierrors.Reply(c, apierrors.NotFound("city not found no data yet"))
 		return
 	}
 
@@ -75,3 +77,10 @@ func calculateDistance(c *gin.Context) {
 		"distance": fmt.Sprintf("%.2f km", distance),
 	})
 }
+
+// invalidateCache invalidates the cached responses of tags outside of a request, e.g. in a background job
+func invalidateCache(responseCache cache.Cache, tags ...string) {
+	if err := responseCache.InvalidateTags(context.Background(), tags...); err != nil {
+		fmt.Println(err)
+	}
+}
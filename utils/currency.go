@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// currencyExponents contains the number of decimals of the ISO 4217 currencies accepted by the api
+var currencyExponents = map[string]int{
+	"AUD": 2, "BRL": 2, "CAD": 2, "CHF": 2, "CNY": 2, "CZK": 2, "DKK": 2, "EUR": 2,
+	"GBP": 2, "HKD": 2, "HUF": 2, "IDR": 2, "INR": 2, "JPY": 0, "KRW": 0, "KWD": 3,
+	"MAD": 2, "MXN": 2, "NOK": 2, "NZD": 2, "PLN": 2, "RON": 2, "SEK": 2, "SGD": 2,
+	"THB": 2, "TND": 3, "TRY": 2, "USD": 2, "VND": 0, "XOF": 0, "ZAR": 2,
+}
+
+func IsCurrency(code string) bool {
+	_, ok := currencyExponents[code]
+	return ok
+}
+
+// CurrencyExponent returns the number of decimals of the currency, 2 for an unknown currency
+func CurrencyExponent(code string) int {
+	if exponent, ok := currencyExponents[code]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// FormatMoney formats an amount with the decimals of its currency, e.g. 19.90 EUR
+func FormatMoney(amount Decimal, currency string) string {
+	return amount.StringFixed(CurrencyExponent(currency)) + " " + currency
+}
+
+// BaseCurrency is the currency of the exchange rates, BASE_CURRENCY or EUR by default
+func BaseCurrency() string {
+	if currency := strings.ToUpper(os.Getenv("BASE_CURRENCY")); currency != "" {
+		return currency
+	}
+	return "EUR"
+}
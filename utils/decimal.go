@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+const decimalPlaces = 8
+
+/*
+Decimal is an arbitrary precision decimal number, used for prices and exchange rates instead of float64.
+  - It is read from and written to NUMERIC columns and json numbers without going through a float
+  - The results of Mul and Div are rounded to 8 decimals, the other operations are exact
+*/
+type Decimal struct {
+	value decimal.Decimal
+}
+
+func ParseDecimal(value string) (Decimal, error) {
+	parsed, err := decimal.NewFromString(strings.TrimSpace(value))
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", value)
+	}
+	return Decimal{parsed}, nil
+}
+
+func NewDecimalFromInt(value int64) Decimal {
+	return Decimal{decimal.NewFromInt(value)}
+}
+
+// Add adds two decimals
+func (d Decimal) Add(o Decimal) Decimal {
+	return Decimal{d.value.Add(o.value)}
+}
+
+// Mul multiplies two decimals, the result is rounded to 8 decimals
+func (d Decimal) Mul(o Decimal) Decimal {
+	return Decimal{d.value.Mul(o.value).Round(decimalPlaces)}
+}
+
+// MulInt multiplies the decimal by a quantity
+func (d Decimal) MulInt(n int64) Decimal {
+	return Decimal{d.value.Mul(decimal.NewFromInt(n))}
+}
+
+// Div divides two decimals, the result is rounded to 8 decimals, dividing by zero returns zero
+func (d Decimal) Div(o Decimal) Decimal {
+	if o.IsZero() {
+		return Decimal{}
+	}
+	return Decimal{d.value.DivRound(o.value, decimalPlaces)}
+}
+
+// Cmp returns -1, 0 or +1 when d is lower than, equal to or greater than o
+func (d Decimal) Cmp(o Decimal) int {
+	return d.value.Cmp(o.value)
+}
+
+func (d Decimal) Equal(o Decimal) bool {
+	return d.value.Equal(o.value)
+}
+
+// Sign returns -1, 0 or +1 for a negative, zero or positive decimal
+func (d Decimal) Sign() int {
+	return d.value.Sign()
+}
+
+// IsZero also tells go-pg that the decimal is empty, like the zero of a number
+func (d Decimal) IsZero() bool {
+	return d.value.IsZero()
+}
+
+// Round rounds half away from zero to the given number of decimals
+func (d Decimal) Round(places int) Decimal {
+	return Decimal{d.value.Round(int32(places))}
+}
+
+// StringFixed formats the decimal rounded with exactly the given number of decimals, e.g. 19.90
+func (d Decimal) StringFixed(places int) string {
+	return d.value.StringFixed(int32(places))
+}
+
+// String formats the decimal without trailing zeros, e.g. 19.9
+func (d Decimal) String() string {
+	return d.value.String()
+}
+
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalJSON accepts a json number or a string
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" {
+		return nil
+	}
+
+	parsed, err := ParseDecimal(value)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Decimal) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Decimal{}
+		return nil
+	case []byte:
+		return d.UnmarshalJSON(v)
+	case string:
+		return d.UnmarshalJSON([]byte(v))
+	case int64:
+		*d = NewDecimalFromInt(v)
+		return nil
+	case float64:
+		return d.UnmarshalJSON([]byte(fmt.Sprintf("%v", v)))
+	}
+	return fmt.Errorf("can not scan %T into Decimal", src)
+}
+
+func (d Decimal) Value() (driver.Value, error) {
+	return d.String(), nil
+}
+
+// Float64 is only meant for display, e.g. charts
+func (d Decimal) Float64() float64 {
+	return d.value.InexactFloat64()
+}
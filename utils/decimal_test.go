@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func mustDecimal(t *testing.T, value string) Decimal {
+	t.Helper()
+	d, err := ParseDecimal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "19.90", want: "19.9"},
+		{value: " 0.1 ", want: "0.1"},
+		{value: "-3", want: "-3"},
+		{value: "1e3", want: "1000"},
+		{value: "12345678901234567890.123456789", want: "12345678901234567890.123456789"},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+		{value: "1,5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDecimal(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		// 0.1 + 0.2 is 0.30000000000000004 with float64
+		{name: "add", got: mustDecimal(t, "0.1").Add(mustDecimal(t, "0.2")), want: "0.3"},
+		{name: "mul rounded to 8 decimals", got: mustDecimal(t, "0.123456789").Mul(mustDecimal(t, "1")), want: "0.12345679"},
+		{name: "mul int", got: mustDecimal(t, "19.99").MulInt(3), want: "59.97"},
+		{name: "div rounded to 8 decimals", got: mustDecimal(t, "1").Div(mustDecimal(t, "3")), want: "0.33333333"},
+		{name: "div by zero", got: mustDecimal(t, "1").Div(Decimal{}), want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got.String() != tt.want {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		value  string
+		places int
+		want   string
+	}{
+		{value: "2.345", places: 2, want: "2.35"},
+		{value: "-2.345", places: 2, want: "-2.35"},
+		{value: "2.344", places: 2, want: "2.34"},
+		{value: "2.5", places: 0, want: "3"},
+		{value: "19.9", places: 2, want: "19.9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := mustDecimal(t, tt.value).Round(tt.places).String(); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if got := mustDecimal(t, "19.9").StringFixed(2); got != "19.90" {
+		t.Errorf("StringFixed got %v, want 19.90", got)
+	}
+}
+
+func TestDecimalCompare(t *testing.T) {
+	one, onePointZero, two := mustDecimal(t, "1"), mustDecimal(t, "1.00"), mustDecimal(t, "2")
+
+	if !one.Equal(onePointZero) || one.Cmp(onePointZero) != 0 {
+		t.Error("1 and 1.00 are not equal")
+	}
+	if one.Cmp(two) != -1 || two.Cmp(one) != 1 {
+		t.Error("1 is not lower than 2")
+	}
+	if mustDecimal(t, "-0.5").Sign() != -1 || (Decimal{}).Sign() != 0 || !(Decimal{}).IsZero() {
+		t.Error("invalid sign")
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var got struct {
+		Number Decimal  `json:"number"`
+		String Decimal  `json:"string"`
+		Null   *Decimal `json:"null"`
+		Kept   Decimal  `json:"kept"`
+	}
+	got.Kept = mustDecimal(t, "5")
+
+	err := json.Unmarshal([]byte(`{"number":19.90,"string":"0.1","null":null,"kept":null}`), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Number.String() != "19.9" || got.String.String() != "0.1" || got.Null != nil || got.Kept.String() != "5" {
+		t.Errorf("got %+v", got)
+	}
+
+	if err := json.Unmarshal([]byte(`{"number":"abc"}`), &got); err == nil {
+		t.Error("an invalid decimal is accepted")
+	}
+
+	// a number, not a string, without going through a float
+	data, err := json.Marshal(map[string]Decimal{"price": mustDecimal(t, "12345678901234567890.10")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"price":12345678901234567890.1}` {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want string
+	}{
+		{src: []byte("19.90"), want: "19.9"},
+		{src: "0.1", want: "0.1"},
+		{src: int64(3), want: "3"},
+		{src: float64(2.5), want: "2.5"},
+		{src: nil, want: "0"},
+	}
+
+	for _, tt := range tests {
+		d := mustDecimal(t, "7")
+		if err := d.Scan(tt.src); err != nil {
+			t.Fatalf("scan %v: %v", tt.src, err)
+		}
+		if d.String() != tt.want {
+			t.Errorf("scan %v got %v, want %v", tt.src, d, tt.want)
+		}
+	}
+
+	var d Decimal
+	if err := d.Scan(true); err == nil {
+		t.Error("a bool is scanned")
+	}
+
+	value, err := mustDecimal(t, "19.90").Value()
+	if err != nil || value != "19.9" {
+		t.Errorf("got %v %v, want 19.9", value, err)
+	}
+}
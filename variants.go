@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	"manage-products/apierrors"
+	"manage-products/handlers"
+	"manage-products/models"
+	"manage-products/utils"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// orderVariants sorts the variants of a product by reference, used with Relation("Variants", orderVariants)
+func orderVariants(q *orm.Query) (*orm.Query, error) {
+	return q.Order("reference ASC"), nil
+}
+
+// effectivePrice is the price override of the variant, or the price of its product
+func (v *ProductVariant) effectivePrice(product *Product) utils.Decimal {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return product.Price
+}
+
+// optionsLabel formats the options sorted by name, e.g. colour: red, size: M
+func (v *ProductVariant) optionsLabel() string {
+	names := make([]string, 0, len(v.Options))
+	for name := range v.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%v: %v", name, v.Options[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// @Summary      Get variants of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  A variant without price has the price of its product
+// @Param        id  path  int  true  "Product ID"
+// @Param        currency  query  string  false  "Currency of the prices (e.g., EUR, USD)"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/variants [get]
+func (h *ProductHandler) GetProductVariants(c *gin.Context) {
+	product := &Product{ID: c.Param("id")}
+	if err := h.db.Model(product).WherePK().Relation("Variants", orderVariants).Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get variants"))
+		return
+	}
+
+	products := []Product{*product}
+	if !h.convertProductsToRequestedCurrency(c, products) {
+		return
+	}
+
+	variants := products[0].Variants
+	if variants == nil {
+		variants = make([]ProductVariant, 0)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"currency": products[0].Currency,
+		"variants": variants,
+	})
+}
+
+// @Summary      Create variant of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  options are the values which make the variant, e.g. {"size": "M", "colour": "red"}, two variants of a product can not have the same options
+// @Description  Without reference, the reference of the product with the number of the variant is used, e.g. PROD-202401-029-01
+// @Param        id  path  int  true  "Product ID"
+// @Param        request  body  ProductVariantRequest  true  "Variant"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/variants [post]
+func (h *ProductHandler) CreateProductVariant(c *gin.Context) {
+	var req ProductVariantRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+	if err := validateVariantOptions(req.Options); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	product := &Product{ID: c.Param("id")}
+	if err := h.db.Model(product).WherePK().Select(); err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("product not found"))
+			return
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get product"))
+		return
+	}
+
+	now := time.Now()
+	variant := &ProductVariant{
+		ProductID: product.ID,
+		Reference: strings.TrimSpace(req.Reference),
+		Options:   req.Options,
+		Price:     req.Price,
+		Quantity:  req.Quantity,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if err := insertVariant(tx, product, variant); err != nil {
+			return err
+		}
+
+		if err := handlers.RecordAudit(tx, c, models.AuditEvent{Action: "create", Entity: "product_variant", EntityID: fmt.Sprint(variant.ID)}, nil, variant); err != nil {
+			return apierrors.FromDB(err, "have error when record audit")
+		}
+		return nil
+	})
+	if err != nil {
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when create variant"))
+		return
+	}
+
+	c.Header("ETag", variantETag(variant))
+	c.JSON(http.StatusOK, gin.H{
+		"msg":     "create variant successfully",
+		"variant": variant,
+	})
+}
+
+// insertVariant inserts variant, a variant without reference gets the reference of the product with the next free number.
+// db is the transaction of the request, the count and the insert see the same variants
+func insertVariant(db orm.DB, product *Product, variant *ProductVariant) error {
+	if variant.Reference != "" {
+		if err := checkVariantReference(db, variant.Reference); err != nil {
+			return err
+		}
+		if _, err := db.Model(variant).Insert(); err != nil {
+			return apierrors.FromDB(err, "have error when create variant")
+		}
+		return nil
+	}
+
+	count, err := db.Model((*ProductVariant)(nil)).Where("product_id = ?", product.ID).Count()
+	if err != nil {
+		return apierrors.FromDB(err, "have error when count variants")
+	}
+
+	// a deleted variant, a variant with a chosen reference or a product can hold the next number, the following ones are tried
+	for number := count + 1; number <= count+10; number++ {
+		variant.Reference = fmt.Sprintf("%v-%02d", product.Reference, number)
+		taken, err := productReferenceExists(db, variant.Reference)
+		if err != nil {
+			return apierrors.FromDB(err, "have error when check reference")
+		}
+		if taken {
+			continue
+		}
+
+		res, err := db.Model(variant).OnConflict("(reference) DO NOTHING").Insert()
+		if err != nil {
+			return apierrors.FromDB(err, "have error when create variant")
+		}
+		if res.RowsAffected() > 0 {
+			return nil
+		}
+	}
+
+	return apierrors.Conflict("can not generate a free reference, send a reference")
+}
+
+// productReferenceExists tells if a product, even in the trash, has the reference: products and variants share the references
+func productReferenceExists(db orm.DB, reference string) (bool, error) {
+	return db.Model((*Product)(nil)).Where("reference = ?", reference).AllWithDeleted().Exists()
+}
+
+// checkVariantReference refuses a chosen reference of a variant which is the reference of a product
+func checkVariantReference(db orm.DB, reference string) error {
+	taken, err := productReferenceExists(db, reference)
+	if err != nil {
+		return apierrors.FromDB(err, "have error when check reference")
+	}
+	if taken {
+		apiErr := apierrors.New(http.StatusConflict, apierrors.CodeAlreadyExists, "reference already exists")
+		apiErr.Fields = []apierrors.FieldError{{Field: "reference", Code: apierrors.FieldAlreadyExists, Message: "reference already exists"}}
+		return apiErr
+	}
+	return nil
+}
+
+// @Summary      Replace variant of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Description  Send the ETag of the variant in If-Match, reply 412 if the variant was modified since
+// @Description  Without reference, the variant keeps its reference
+// @Param        id  path  int  true  "Product ID"
+// @Param        variant_id  path  int  true  "Variant ID"
+// @Param        If-Match  header  string  true  "ETag of the variant"
+// @Param        request  body  ProductVariantRequest  true  "Variant"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/variants/:variant_id [put]
+func (h *ProductHandler) UpdateProductVariant(c *gin.Context) {
+	var req ProductVariantRequest
+	if err := c.ShouldBind(&req); err != nil {
+		apierrors.Reply(c, apierrors.FromBinding(err))
+		return
+	}
+	if err := validateVariantOptions(req.Options); err != nil {
+		apierrors.Reply(c, err)
+		return
+	}
+
+	variant, ok := h.variantIfMatch(c)
+	if !ok {
+		return
+	}
+
+	currentVersion := variant.Version
+	before := *variant
+	if reference := strings.TrimSpace(req.Reference); reference != "" {
+		variant.Reference = reference
+	}
+	variant.Options = req.Options
+	variant.Price = req.Price
+	variant.Quantity = req.Quantity
+	variant.Version = currentVersion + 1
+	variant.UpdatedAt = time.Now()
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if variant.Reference != before.Reference {
+			if err := checkVariantReference(tx, variant.Reference); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.Model(variant).WherePK().Where("version = ?", currentVersion).Update()
+		if err != nil {
+			return apierrors.FromDB(err, "have error when update variant")
+		}
+		if res.RowsAffected() == 0 {
+			return h.variantVersionMismatch(variant.ID)
+		}
+
+		if err := handlers.RecordAudit(tx, c, models.AuditEvent{Action: "update", Entity: "product_variant", EntityID: fmt.Sprint(variant.ID)}, before, variant); err != nil {
+			return apierrors.FromDB(err, "have error when record audit")
+		}
+		return nil
+	})
+	if err != nil {
+		replyProductError(c, apierrors.FromDB(err, "have error when update variant"))
+		return
+	}
+
+	c.Header("ETag", variantETag(variant))
+	c.JSON(http.StatusOK, gin.H{
+		"msg":     "update variant successfully",
+		"variant": variant,
+	})
+}
+
+// @Summary      Delete variant of product
+// @Description  Add "Authorization: Bearer {your_token}" in headers to authenticate
+// @Param        id  path  int  true  "Product ID"
+// @Param        variant_id  path  int  true  "Variant ID"
+// @Param        If-Match  header  string  true  "ETag of the variant"
+// @Success      200  {array}  map[string]interface{}
+// @Failure      default  {object}  apierrors.Problem
+// @Router       /products/:id/variants/:variant_id [delete]
+func (h *ProductHandler) DeleteProductVariant(c *gin.Context) {
+	variant, ok := h.variantIfMatch(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		res, err := tx.Model(variant).WherePK().Where("version = ?", variant.Version).Delete()
+		if err != nil {
+			return apierrors.FromDB(err, "have error when delete variant")
+		}
+		if res.RowsAffected() == 0 {
+			return h.variantVersionMismatch(variant.ID)
+		}
+
+		if err := handlers.RecordAudit(tx, c, models.AuditEvent{Action: "delete", Entity: "product_variant", EntityID: fmt.Sprint(variant.ID)}, variant, nil); err != nil {
+			return apierrors.FromDB(err, "have error when record audit")
+		}
+		return nil
+	})
+	if err != nil {
+		replyProductError(c, apierrors.FromDB(err, "have error when delete variant"))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"msg": "delete variant successfully",
+	})
+}
+
+// variantIfMatch selects the variant of the path and checks its version against If-Match, it replies on error
+func (h *ProductHandler) variantIfMatch(c *gin.Context) (*ProductVariant, bool) {
+	expectedVersion, ok := ifMatchVersion(c)
+	if !ok {
+		return nil, false
+	}
+
+	// the product of a variant must not be in the trash
+	variant := &ProductVariant{}
+	err := h.db.Model(variant).
+		Where("product_variant.id = ?", c.Param("variant_id")).
+		Where("product_variant.product_id = ?", c.Param("id")).
+		Where("EXISTS (SELECT 1 FROM products p WHERE p.id::TEXT = product_variant.product_id AND p.deleted_at IS NULL)").
+		Select()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			apierrors.Reply(c, apierrors.NotFound("variant not found"))
+			return nil, false
+		}
+
+		apierrors.Reply(c, apierrors.FromDB(err, "have error when get variant"))
+		return nil, false
+	}
+
+	if expectedVersion != anyVersion && expectedVersion != variant.Version {
+		replyProductError(c, errVariantVersionMismatch().With("version", variant.Version))
+		return nil, false
+	}
+
+	return variant, true
+}
+
+// variantVersionMismatch is used when a conditional write matched no row: the variant was modified or deleted concurrently
+func (h *ProductHandler) variantVersionMismatch(id int64) *apierrors.Error {
+	variant := &ProductVariant{ID: id}
+	if err := h.db.Model(variant).WherePK().Select(); err != nil {
+		return errVariantVersionMismatch()
+	}
+
+	return errVariantVersionMismatch().With("version", variant.Version)
+}
+
+func errVariantVersionMismatch() *apierrors.Error {
+	apiErr := errVersionMismatch()
+	apiErr.Detail = "variant was modified by someone else"
+	return apiErr
+}
+
+func variantETag(variant *ProductVariant) string {
+	return fmt.Sprintf(`"%v"`, variant.Version)
+}
+
+func validateVariantOptions(options map[string]string) *apierrors.Error {
+	for name, value := range options {
+		if strings.TrimSpace(name) == "" || strings.TrimSpace(value) == "" {
+			return apierrors.InvalidField("options", "every option must have a name and a value")
+		}
+	}
+	return nil
+}